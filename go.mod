@@ -4,9 +4,12 @@ go 1.15
 
 require (
 	github.com/google/go-github/v32 v32.1.0
+	github.com/hashicorp/hcl/v2 v2.6.0
 	github.com/hashicorp/terraform v0.13.5
 	github.com/sirupsen/logrus v1.7.0
 	github.com/spf13/cobra v1.1.1
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
+	github.com/zclconf/go-cty v1.5.1
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 )
@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const accessMatrixTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Organization}} access matrix</title>
+<style>
+  body { font-family: sans-serif; font-size: 13px; }
+  table { border-collapse: collapse; }
+  th, td { border: 1px solid #ccc; padding: 4px 6px; text-align: center; white-space: nowrap; }
+  th.user { text-align: left; }
+  thead th { position: sticky; top: 0; background: #f6f6f6; }
+  td.admin { background: #f4a6a6; }
+  td.maintain { background: #f6c48b; }
+  td.push { background: #f6e58b; }
+  td.triage { background: #c6e5a8; }
+  td.pull { background: #d8e6f6; }
+</style>
+</head>
+<body>
+<h1>{{.Organization}} access matrix</h1>
+<p>Effective repository permission of every user, generated on {{.CreatedAt.Format "2006-01-02 15:04 MST"}}. Hover a permission to see what grants it.</p>
+<table>
+  <thead>
+    <tr>
+      <th class="user">User</th>
+      {{- range .Repositories}}
+      <th>{{.}}</th>
+      {{- end}}
+    </tr>
+  </thead>
+  <tbody>
+    {{- range $user := .Users}}
+    <tr>
+      <th class="user">{{$user}}</th>
+      {{- range $repo := $.Repositories}}
+      {{- with $.Entry $repo $user}}
+      <td class="{{.Permission}}" title="{{.GrantsText}}">{{.Permission}}</td>
+      {{- else}}
+      <td></td>
+      {{- end}}
+      {{- end}}
+    </tr>
+    {{- end}}
+  </tbody>
+</table>
+</body>
+</html>
+`
+
+// accessGrant is a permission given to a user on a repository, along with what gives it
+type accessGrant struct {
+	Permission string
+	Source     string
+}
+
+// accessEntry is the effective permission of a user on a repository, the highest of its grants
+type accessEntry struct {
+	Repository string
+	User       string
+	Permission string
+	Grants     []accessGrant
+}
+
+// Sources returns what gives the effective permission
+func (e *accessEntry) Sources() []string {
+	var sources []string
+	for _, grant := range e.Grants {
+		if grant.Permission == e.Permission {
+			sources = append(sources, grant.Source)
+		}
+	}
+
+	return sources
+}
+
+// GrantsText lists every grant of the entry
+func (e *accessEntry) GrantsText() string {
+	var grants []string
+	for _, grant := range e.Grants {
+		grants = append(grants, fmt.Sprintf("%s: %s", grant.Source, grant.Permission))
+	}
+
+	return strings.Join(grants, "; ")
+}
+
+// accessMatrix holds the effective permission of every user on every repository
+type accessMatrix struct {
+	Organization string
+	CreatedAt    time.Time
+	Repositories []string
+	Users        []string
+	entries      map[string]map[string]*accessEntry
+}
+
+func (s *session) newAccessMatrix() *accessMatrix {
+	m := &accessMatrix{
+		Organization: s.orgName,
+		CreatedAt:    time.Now().UTC(),
+		entries:      make(map[string]map[string]*accessEntry),
+	}
+	if s.archive != nil {
+		m.CreatedAt = s.archive.CreatedAt
+	}
+
+	return m
+}
+
+// grant adds a permission on the repository to the user, no access permissions are ignored
+func (m *accessMatrix) grant(repo, user, permission, source string) {
+	if permissionRank(permission) == 0 {
+		return
+	}
+
+	if m.entries[repo] == nil {
+		m.entries[repo] = make(map[string]*accessEntry)
+	}
+
+	entry, ok := m.entries[repo][user]
+	if !ok {
+		entry = &accessEntry{Repository: repo, User: user}
+		m.entries[repo][user] = entry
+		if !contains(m.Users, user) {
+			m.Users = append(m.Users, user)
+		}
+	}
+
+	entry.Grants = append(entry.Grants, accessGrant{Permission: permission, Source: source})
+	if permissionRank(permission) > permissionRank(entry.Permission) {
+		entry.Permission = permission
+	}
+}
+
+// Entry returns the permission of the user on the repository, nil when the user has no access
+func (m *accessMatrix) Entry(repo, user string) *accessEntry {
+	return m.entries[repo][user]
+}
+
+func init() {
+	rootCmd.AddCommand(accessMatrixCmd)
+}
+
+var accessMatrixCmd = &cobra.Command{
+	Use:   "access-matrix",
+	Short: "Report the effective permission of every user on every repository, and what grants it, as CSV and HTML",
+	Long: `Report who can access each repository of the organization and why.
+
+  Combines the organization base permission, the organization owners, the team
+  repository grants, including the ones inherited from parent teams, and the
+  repository collaborators into the effective permission of every user on every
+  repository. Writes access_matrix.csv and access_matrix.html to the output directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if userMode {
+			log.Error("access-matrix requires an organization, it can't be used with --user")
+			return
+		}
+
+		forEachOrganization(func(s *session) {
+			s.log.Debug("Getting access matrix data")
+
+			matrix, err := s.getAccessMatrix()
+			if err != nil {
+				s.log.Error(err)
+				return
+			}
+
+			if err := writeAccessMatrix(matrix, s.outDirectory); err != nil {
+				s.log.Error(err)
+				return
+			}
+
+			s.log.WithFields(logrus.Fields{
+				"Directory":    s.outDirectory,
+				"Repositories": len(matrix.Repositories),
+				"Users":        len(matrix.Users),
+			}).Info("Access matrix saved")
+		})
+	},
+}
+
+func (s *session) getAccessMatrix() (*accessMatrix, error) {
+	org, err := s.getOrganization()
+	if err != nil {
+		return nil, err
+	}
+	basePermission := apiPermission(org.GetDefaultRepoPermission())
+
+	roles, err := s.getOrgMemberRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := s.getOrgTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := newTeamHierarchy(teams)
+	memberships, err := s.getOrgTeamsMemberships(teams)
+	if err != nil {
+		return nil, err
+	}
+
+	teamGrants, err := s.getOrgTeamsRepositories(teams)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := s.newAccessMatrix()
+
+	for _, repo := range repos {
+		matrix.Repositories = append(matrix.Repositories, repo.GetName())
+
+		for _, login := range sortedKeys(roles) {
+			if roles[login] == "admin" {
+				matrix.grant(repo.GetName(), login, "admin", "organization owner")
+			}
+			matrix.grant(repo.GetName(), login, basePermission, "organization base permission")
+		}
+	}
+
+	for _, team := range teams {
+		s.log.WithFields(logrus.Fields{
+			"Team": team.GetName(),
+		}).Debug("Processing team access")
+
+		for _, repo := range teamGrants[team.GetID()] {
+			permission := highestPermission(repo.GetPermissions())
+
+			source := fmt.Sprintf("team %s", team.GetSlug())
+			if parent := teamGrants.inheritedFrom(team, repo, permission, hierarchy); parent != nil {
+				source = fmt.Sprintf("team %s, inherited from %s", team.GetSlug(), parent.GetSlug())
+			}
+
+			for _, role := range []string{"maintainer", "member"} {
+				for _, member := range memberships[team.GetID()][role] {
+					matrix.grant(repo.GetName(), member.GetLogin(), permission, source)
+				}
+			}
+		}
+	}
+
+	for _, repo := range repos {
+		if err := s.accessMatrixCollaborators(matrix, repo, roles); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(matrix.Repositories)
+	sort.Strings(matrix.Users)
+
+	return matrix, nil
+}
+
+// accessMatrixCollaborators adds the direct collaborator grants of the repository. Github reports the
+// effective permission of collaborators, so a collaboration is only considered a grant of its own when
+// its permission is higher than what the organization and the teams already give.
+func (s *session) accessMatrixCollaborators(matrix *accessMatrix, repo *github.Repository, roles map[string]string) error {
+	collaborators, err := s.getOrgRepositoryCollaborators(repo, "direct")
+	if err == errCollaboratorsUnreadable {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, collaborator := range collaborators {
+		login := collaborator.GetLogin()
+		permission := highestPermission(collaborator.GetPermissions())
+
+		current := ""
+		if entry := matrix.Entry(repo.GetName(), login); entry != nil {
+			current = entry.Permission
+		}
+		if permissionRank(permission) <= permissionRank(current) {
+			continue
+		}
+
+		source := "collaborator"
+		if _, member := roles[login]; !member {
+			source = "outside collaborator"
+		}
+
+		matrix.grant(repo.GetName(), login, permission, source)
+	}
+
+	return nil
+}
+
+// writeAccessMatrix writes the matrix as CSV, one line per user and repository, and as an HTML page
+func writeAccessMatrix(matrix *accessMatrix, dir string) error {
+	var data bytes.Buffer
+
+	w := csv.NewWriter(&data)
+	w.Write([]string{"repository", "user", "permission", "sources", "grants"})
+	for _, repo := range matrix.Repositories {
+		for _, user := range matrix.Users {
+			entry := matrix.Entry(repo, user)
+			if entry == nil {
+				continue
+			}
+			w.Write([]string{repo, user, entry.Permission, strings.Join(entry.Sources(), ";"), entry.GrantsText()})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "access_matrix.csv"), data.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	var page bytes.Buffer
+	tmpl := template.Must(template.New("access-matrix").Parse(accessMatrixTemplate))
+	if err := tmpl.Execute(&page, matrix); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "access_matrix.html"), page.Bytes(), 0644)
+}
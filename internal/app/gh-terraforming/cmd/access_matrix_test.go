@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccessMatrixGrant(t *testing.T) {
+	type grant struct {
+		repo, user, permission, source string
+	}
+
+	tests := []struct {
+		name        string
+		grants      []grant
+		wantUsers   []string
+		wantEntry   *accessEntry
+		wantSources []string
+	}{
+		{
+			name:   "no access ignored",
+			grants: []grant{{"api", "alice", "", "organization base permission"}},
+		},
+		{
+			name:        "single grant",
+			grants:      []grant{{"api", "alice", "pull", "organization base permission"}},
+			wantUsers:   []string{"alice"},
+			wantEntry:   &accessEntry{Repository: "api", User: "alice", Permission: "pull", Grants: []accessGrant{{"pull", "organization base permission"}}},
+			wantSources: []string{"organization base permission"},
+		},
+		{
+			name: "highest grant wins",
+			grants: []grant{
+				{"api", "alice", "pull", "organization base permission"},
+				{"api", "alice", "admin", "team core"},
+				{"api", "alice", "push", "collaborator"},
+			},
+			wantUsers: []string{"alice"},
+			wantEntry: &accessEntry{Repository: "api", User: "alice", Permission: "admin", Grants: []accessGrant{
+				{"pull", "organization base permission"},
+				{"admin", "team core"},
+				{"push", "collaborator"},
+			}},
+			wantSources: []string{"team core"},
+		},
+		{
+			name: "same permission from several sources",
+			grants: []grant{
+				{"api", "alice", "push", "team core"},
+				{"web", "bob", "pull", "organization base permission"},
+				{"api", "alice", "push", "team ops"},
+			},
+			wantUsers: []string{"alice", "bob"},
+			wantEntry: &accessEntry{Repository: "api", User: "alice", Permission: "push", Grants: []accessGrant{
+				{"push", "team core"},
+				{"push", "team ops"},
+			}},
+			wantSources: []string{"team core", "team ops"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &accessMatrix{entries: make(map[string]map[string]*accessEntry)}
+			for _, g := range tt.grants {
+				m.grant(g.repo, g.user, g.permission, g.source)
+			}
+
+			if !reflect.DeepEqual(m.Users, tt.wantUsers) {
+				t.Errorf("users = %v, want %v", m.Users, tt.wantUsers)
+			}
+
+			entry := m.Entry("api", "alice")
+			if !reflect.DeepEqual(entry, tt.wantEntry) {
+				t.Fatalf("Entry(api, alice) = %+v, want %+v", entry, tt.wantEntry)
+			}
+			if entry != nil && !reflect.DeepEqual(entry.Sources(), tt.wantSources) {
+				t.Errorf("Sources() = %v, want %v", entry.Sources(), tt.wantSources)
+			}
+		})
+	}
+}
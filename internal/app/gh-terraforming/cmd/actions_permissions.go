@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+const actionsAllowedActionsConfigTemplate = `
+{{- define "actions-allowed-actions-config"}}
+  {{- with .SelectedActions}}
+
+  allowed_actions_config {
+    github_owned_allowed = {{hclValue .GithubOwnedAllowed}}
+    {{- attr "verified_allowed" .VerifiedAllowed false}}
+    {{- attr "patterns_allowed" .PatternsAllowed nil}}
+  }
+  {{- end}}
+{{- end}}
+`
+
+const actionsOrganizationPermissionsTemplate = `
+# terraform import github_actions_organization_permissions.{{normalizeResourceName .Org}} {{.Org}}
+resource "github_actions_organization_permissions" "{{normalizeResourceName .Org}}" {
+  enabled_repositories = {{hclValue .Permissions.EnabledRepositories}}
+  {{- attr "allowed_actions" .Permissions.AllowedActions ""}}
+  {{- template "actions-allowed-actions-config" .}}
+  {{- if .RepositoryIDs}}
+
+  enabled_repositories_config {
+    repository_ids = [ {{range $i, $id := .RepositoryIDs}}{{if $i}}, {{end}}{{$id}}{{end}} ]
+  }
+  {{- end}}
+}
+`
+
+const actionsRepositoryPermissionsTemplate = `
+{{- if hasLeadingDigit .RepoName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}
+{{- end}}
+# terraform import github_actions_repository_permissions.{{normalizeResourceName .RepoName}} {{.RepoName}}
+resource "github_actions_repository_permissions" "{{normalizeResourceName .RepoName}}" {
+  repository      = {{hclValue .RepoName}}
+  {{- attr "enabled" .Permissions.Enabled true}}
+  {{- if .Permissions.Enabled}}
+  allowed_actions = {{hclValue .Permissions.AllowedActions}}
+  {{- end}}
+  {{- template "actions-allowed-actions-config" .}}
+}
+`
+
+const actionsRepositoryAccessLevelTemplate = `
+{{- if hasLeadingDigit .RepoName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}
+{{- end}}
+# terraform import github_actions_repository_access_level.{{normalizeResourceName .RepoName}} {{.RepoName}}
+resource "github_actions_repository_access_level" "{{normalizeResourceName .RepoName}}" {
+  repository   = {{hclValue .RepoName}}
+  access_level = {{hclValue .AccessLevel}}
+}
+`
+
+// Actions permissions types, go-github doesn't support these endpoints yet
+type ActionsPermissions struct {
+	// organization only
+	EnabledRepositories string `json:"enabled_repositories"`
+	// repository only
+	Enabled bool `json:"enabled"`
+
+	AllowedActions string `json:"allowed_actions"`
+}
+
+type ActionsSelectedActions struct {
+	GithubOwnedAllowed bool     `json:"github_owned_allowed"`
+	VerifiedAllowed    bool     `json:"verified_allowed"`
+	PatternsAllowed    []string `json:"patterns_allowed"`
+}
+
+type actionsRepositoryAccess struct {
+	AccessLevel string `json:"access_level"`
+}
+
+var actionsPermissionsCmd = RegisterGenerator(&generator{
+	name:  "actions-permissions",
+	fetch: (*session).actionsPermissionsFetch,
+	templates: map[string]string{
+		"github_actions_organization_permissions": actionsOrganizationPermissionsTemplate,
+		"github_actions_repository_permissions":   actionsRepositoryPermissionsTemplate,
+		"github_actions_repository_access_level":  actionsRepositoryAccessLevelTemplate,
+	},
+	shared: map[string]string{"actions-allowed-actions-config": actionsAllowedActionsConfigTemplate},
+}, "Import organization and repository Actions permissions into Terraform")
+
+func (s *session) actionsPermissionsFetch() ([]*Resource, error) {
+	s.log.Debug("Getting Actions permissions data")
+
+	// repositories are used to reference the generated github_repository resources
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+
+	// personal accounts only have repository permissions
+	var orgPermissions *ActionsPermissions
+	if !s.userMode {
+		if orgPermissions, err = s.getActionsPermissions(fmt.Sprintf("orgs/%s/actions/permissions", s.orgName)); err != nil {
+			return nil, err
+		}
+	}
+
+	if orgPermissions != nil {
+		selectedActions, err := s.getActionsSelectedActions(fmt.Sprintf("orgs/%s/actions/permissions", s.orgName), orgPermissions)
+		if err != nil {
+			return nil, err
+		}
+
+		var enabledRepos []*github.Repository
+		if orgPermissions.EnabledRepositories == "selected" {
+			if enabledRepos, err = s.getActionsEnabledRepositories(); err != nil {
+				return nil, err
+			}
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"Organization": s.orgName,
+		}).Debug("Processing organization Actions permissions")
+
+		resources = append(resources, s.actionsOrganizationPermissionsResource(orgPermissions, selectedActions, enabledRepos, repos))
+	}
+
+	for _, repo := range repos {
+		s.log.WithFields(logrus.Fields{
+			"Repository": repo.GetName(),
+		}).Debug("Processing repository Actions permissions")
+
+		path := fmt.Sprintf("repos/%s/%s/actions/permissions", s.orgName, repo.GetName())
+
+		permissions, err := s.getActionsPermissions(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if permissions != nil {
+			selectedActions, err := s.getActionsSelectedActions(path, permissions)
+			if err != nil {
+				return nil, err
+			}
+
+			resources = append(resources, s.actionsRepositoryPermissionsResource(repo, permissions, selectedActions))
+		}
+
+		// The access level only applies to private and internal repositories
+		if !repo.GetPrivate() {
+			continue
+		}
+
+		access, err := s.getActionsRepositoryAccess(repo)
+		if err != nil {
+			return nil, err
+		}
+		if access == nil {
+			continue
+		}
+
+		resources = append(resources, s.actionsRepositoryAccessLevelResource(repo, access))
+	}
+
+	return resources, nil
+}
+
+// getActionsPermissions returns nil when the token isn't allowed to read the permissions
+func (s *session) getActionsPermissions(path string) (*ActionsPermissions, error) {
+	if s.archive != nil {
+		permissions, err := s.archive.actionsPermissions(path)
+		if err != nil {
+			s.log.Error(err)
+		}
+		return permissions, err
+	}
+
+	permissions := new(ActionsPermissions)
+	resp, err := s.apiGet(path, permissions)
+	if err != nil {
+		// Reading the organization permissions requires an owner, that shouldn't stop the whole import
+		if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+			s.log.WithFields(logrus.Fields{
+				"Path": path,
+			}).Warn("Actions permissions are not available")
+			return nil, nil
+		}
+		s.log.Error(err)
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// getActionsSelectedActions returns the allowed actions configuration, only set when the permissions allow selected actions
+func (s *session) getActionsSelectedActions(path string, permissions *ActionsPermissions) (*ActionsSelectedActions, error) {
+	if permissions == nil || permissions.AllowedActions != "selected" {
+		return nil, nil
+	}
+
+	if s.archive != nil {
+		return s.archive.ActionsSelectedActions[path], nil
+	}
+
+	selectedActions := new(ActionsSelectedActions)
+	if _, err := s.apiGet(fmt.Sprintf("%s/selected-actions", path), selectedActions); err != nil {
+		s.log.Error(err)
+		return nil, err
+	}
+
+	return selectedActions, nil
+}
+
+func (s *session) getActionsEnabledRepositories() ([]*github.Repository, error) {
+	if s.archive != nil {
+		return s.archive.ActionsEnabledRepositories, nil
+	}
+
+	opt := &github.ListOptions{PerPage: 100, Page: 1}
+
+	var allRepos []*github.Repository
+	for {
+		var result struct {
+			Repositories []*github.Repository `json:"repositories"`
+		}
+
+		resp, err := s.apiGet(fmt.Sprintf("orgs/%s/actions/permissions/repositories?per_page=%d&page=%d", s.orgName, opt.PerPage, opt.Page), &result)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		allRepos = append(allRepos, result.Repositories...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	return allRepos, nil
+}
+
+// getActionsRepositoryAccess returns nil when the access level can't be configured for the repository
+func (s *session) getActionsRepositoryAccess(repo *github.Repository) (*actionsRepositoryAccess, error) {
+	if s.archive != nil {
+		return s.archive.repository(repo).ActionsAccess, nil
+	}
+
+	access := new(actionsRepositoryAccess)
+	resp, err := s.apiGet(fmt.Sprintf("repos/%s/%s/actions/permissions/access", s.orgName, repo.GetName()), access)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+			}).Warn("Actions access level is not available")
+			return nil, nil
+		}
+		s.log.Error(err)
+		return nil, err
+	}
+
+	return access, nil
+}
+
+// ActionsOrganizationPermissionsData is passed to the github_actions_organization_permissions template
+type ActionsOrganizationPermissionsData struct {
+	// Org is the organization name
+	Org string
+	// Permissions are the Actions permissions
+	Permissions ActionsPermissions
+	// SelectedActions is set when only selected actions are allowed
+	SelectedActions *ActionsSelectedActions
+	// RepositoryIDs are the references to the repositories allowed to run Actions
+	RepositoryIDs []string
+}
+
+func (s *session) actionsOrganizationPermissionsResource(permissions *ActionsPermissions, selectedActions *ActionsSelectedActions, enabledRepos, repos []*github.Repository) *Resource {
+	// reference the github_repository resources generated in the same run, the others get their ID
+	var repositoryIDs []string
+	for _, enabled := range enabledRepos {
+		repositoryID := strconv.FormatInt(enabled.GetID(), 10)
+		for _, repo := range repos {
+			name := s.normalizeResourceName(repo.GetName())
+			if repo.GetID() == enabled.GetID() && s.generates("repository", "github_repository", name) {
+				repositoryID = fmt.Sprintf("github_repository.%s.repo_id", name)
+				break
+			}
+		}
+		repositoryIDs = append(repositoryIDs, repositoryID)
+	}
+
+	return &Resource{
+		Type:     "github_actions_organization_permissions",
+		Name:     s.normalizeResourceName(s.orgName),
+		ImportID: s.orgName,
+		Data: ActionsOrganizationPermissionsData{
+			Org:             s.orgName,
+			Permissions:     *permissions,
+			SelectedActions: selectedActions,
+			RepositoryIDs:   repositoryIDs,
+		},
+	}
+}
+
+// ActionsRepositoryPermissionsData is passed to the github_actions_repository_permissions template
+type ActionsRepositoryPermissionsData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// Permissions are the Actions permissions
+	Permissions ActionsPermissions
+	// SelectedActions is set when only selected actions are allowed
+	SelectedActions *ActionsSelectedActions
+}
+
+func (s *session) actionsRepositoryPermissionsResource(repo *github.Repository, permissions *ActionsPermissions, selectedActions *ActionsSelectedActions) *Resource {
+	return &Resource{
+		Type:     "github_actions_repository_permissions",
+		Name:     s.normalizeResourceName(repo.GetName()),
+		ImportID: repo.GetName(),
+		Data: ActionsRepositoryPermissionsData{
+			Org:             s.orgName,
+			RepoName:        repo.GetName(),
+			Permissions:     *permissions,
+			SelectedActions: selectedActions,
+		},
+	}
+}
+
+// ActionsRepositoryAccessLevelData is passed to the github_actions_repository_access_level template
+type ActionsRepositoryAccessLevelData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// AccessLevel is the access level of workflows outside of the repository
+	AccessLevel string
+}
+
+func (s *session) actionsRepositoryAccessLevelResource(repo *github.Repository, access *actionsRepositoryAccess) *Resource {
+	return &Resource{
+		Type:     "github_actions_repository_access_level",
+		Name:     s.normalizeResourceName(repo.GetName()),
+		ImportID: repo.GetName(),
+		Data: ActionsRepositoryAccessLevelData{
+			Org:         s.orgName,
+			RepoName:    repo.GetName(),
+			AccessLevel: access.AccessLevel,
+		},
+	}
+}
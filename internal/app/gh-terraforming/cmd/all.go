@@ -1,43 +1,52 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
 
 func init() {
 	rootCmd.AddCommand(allCmd)
 }
 
 var allCmd = &cobra.Command{
-	Use: "all",
+	Use:   "all",
+	Short: "Import all supported Github resources into Terraform",
 	Long: `Import all Github resources into Terraform.
 
-  Currently supported resources:
-  - Memberships
-  - Repositories
-  - Repository collaborators
-  - Repository webhooks
-  - Teams
-  - Team memberships
-  - Team repository`,
+  Runs every registered generator, one per resource command, or the generators
+  listed in the configuration file.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Debug("Importing all supported resources")
 
-		membershipCmd.Run(cmd, args)
-
-		organizationBlockCmd.Run(cmd, args)
-
-		repositoryCmd.Run(cmd, args)
-
-		repositoryBranchCmd.Run(cmd, args)
-
-		repositoryCollaboratorCmd.Run(cmd, args)
-
-		repositoryWebhookCmd.Run(cmd, args)
-
-		teamCmd.Run(cmd, args)
-
-		teamMembershipCmd.Run(cmd, args)
-
-		teamRepositoryCmd.Run(cmd, args)
+		var names []string
+		if config != nil {
+			names = config.Generators
+		}
+
+		selected, err := selectGenerators(names)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		forEachOrganization(func(s *session) {
+			var run []Generator
+			for _, g := range selected {
+				if s.userMode && organizationOnly(g) {
+					s.log.WithFields(logrus.Fields{
+						"Generator": g.Name(),
+					}).Debug("Skipping organization only generator")
+					continue
+				}
+				run = append(run, g)
+			}
+
+			s.generators = generatorNames(run)
+			for _, g := range run {
+				s.runGenerator(g)
+			}
+		})
 	},
 }
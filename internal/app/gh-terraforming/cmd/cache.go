@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cacheTransport stores successful GET responses on disk. Responses younger than the TTL are
+// served straight from the cache, older ones are revalidated with If-None-Match so unchanged
+// resources come back as 304 Not Modified, which doesn't count against the rate limit.
+type cacheTransport struct {
+	dir  string
+	ttl  time.Duration
+	base http.RoundTripper
+}
+
+func newCacheTransport(dir string, ttl time.Duration, base http.RoundTripper) (*cacheTransport, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &cacheTransport{dir: dir, ttl: ttl, base: base}, nil
+}
+
+// cacheKey identifies a request by its URL and the headers that change the response,
+// the token is part of it since different tokens may have access to different data
+func (t *cacheTransport) cacheKey(req *http.Request) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.URL.String()))
+	hash.Write([]byte(req.Header.Get("Accept")))
+	hash.Write([]byte(req.Header.Get("Authorization")))
+
+	return filepath.Join(t.dir, hex.EncodeToString(hash.Sum(nil)))
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	path := t.cacheKey(req)
+	cached, storedAt, err := t.load(path, req)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"URL": req.URL.String(),
+		}).Debug(err)
+	}
+
+	if cached != nil && time.Since(storedAt) < t.ttl {
+		log.WithFields(logrus.Fields{
+			"URL": req.URL.String(),
+		}).Trace("Serving response from cache")
+		return cached, nil
+	}
+
+	if cached != nil && cached.Header.Get("ETag") != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.Header.Get("ETag"))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		log.WithFields(logrus.Fields{
+			"URL": req.URL.String(),
+		}).Trace("Response not modified, serving it from cache")
+
+		resp.Body.Close()
+		// keep the current rate limit headers so the client sees the actual limits
+		for name, values := range resp.Header {
+			cached.Header[name] = values
+		}
+
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			log.Debug(err)
+		}
+
+		return cached, nil
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if err := t.store(path, resp); err != nil {
+			log.WithFields(logrus.Fields{
+				"URL": req.URL.String(),
+			}).Debug(err)
+		}
+	}
+
+	return resp, nil
+}
+
+// load reads a cached response along with the time it was stored or last revalidated,
+// a nil response is returned when the request isn't cached
+func (t *cacheTransport) load(path string, req *http.Request) (*http.Response, time.Time, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	dump, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return resp, info.ModTime(), nil
+}
+
+// store writes the response to the cache, its body is read and replaced so the caller can still consume it
+func (t *cacheTransport) store(path string, resp *http.Response) error {
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, dump, 0600)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is an http.RoundTripper answering with a function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func testResponse(req *http.Request, status int, etag, body string) *http.Response {
+	header := make(http.Header)
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestCacheTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// method of both requests
+		method string
+		// token of the second request, the first one uses "first"
+		token string
+		// status returned by the server to the first request, the second one is answered with
+		// 304 when it has a matching If-None-Match header and with "second" otherwise
+		status    int
+		wantCalls int
+		wantBody  string
+	}{
+		{name: "fresh response served from cache", ttl: time.Hour, method: "GET", token: "first", status: 200, wantCalls: 1, wantBody: "first"},
+		{name: "stale response revalidated", ttl: 0, method: "GET", token: "first", status: 200, wantCalls: 2, wantBody: "first"},
+		{name: "other token not shared", ttl: time.Hour, method: "GET", token: "other", status: 200, wantCalls: 2, wantBody: "second"},
+		{name: "error response not cached", ttl: time.Hour, method: "GET", token: "first", status: 404, wantCalls: 2, wantBody: "second"},
+		{name: "post not cached", ttl: time.Hour, method: "POST", token: "first", status: 200, wantCalls: 2, wantBody: "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				calls++
+				if calls == 1 {
+					return testResponse(req, tt.status, `"v1"`, "first"), nil
+				}
+				if req.Header.Get("If-None-Match") == `"v1"` {
+					return testResponse(req, http.StatusNotModified, `"v1"`, ""), nil
+				}
+				return testResponse(req, http.StatusOK, `"v2"`, "second"), nil
+			})
+
+			transport, err := newCacheTransport(t.TempDir(), tt.ttl, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var body []byte
+			for _, token := range []string{"first", tt.token} {
+				req, err := http.NewRequest(tt.method, "https://api.github.com/orgs/acme/repos", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "token "+token)
+
+				resp, err := transport.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, err = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("server called %d times, want %d", calls, tt.wantCalls)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("second response body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// configName is the name of the configuration file looked up in the working directory, without extension
+const configName = ".gh-terraforming"
+
+// runConfig holds the settings of the configuration file that don't map to a flag
+type runConfig struct {
+	// Generators are the generators run by all, every registered generator when empty
+	Generators []string `mapstructure:"generators"`
+	// Naming is the strategy turning Github names into resource names, default or snake_case
+	Naming string `mapstructure:"naming"`
+	// Layout is how resources are split into files: type (one file per resource type), generator or single
+	Layout string `mapstructure:"layout"`
+	// Resources holds the settings of each resource type
+	Resources map[string]*resourceConfig `mapstructure:"resources"`
+}
+
+// resourceConfig holds the settings of a resource type
+type resourceConfig struct {
+	// Include keeps only the resources whose name matches one of these patterns
+	Include []string `mapstructure:"include"`
+	// Exclude skips the resources whose name matches one of these patterns
+	Exclude []string `mapstructure:"exclude"`
+	// File is the file the resources are written to, overriding the layout
+	File string `mapstructure:"file"`
+	// Lifecycle is added to every resource
+	Lifecycle *lifecycleConfig `mapstructure:"lifecycle"`
+	// Attributes are set on every resource, replacing the generated values
+	Attributes map[string]interface{} `mapstructure:"attributes"`
+}
+
+// lifecycleConfig is the lifecycle block added to the resources
+type lifecycleConfig struct {
+	PreventDestroy      bool     `mapstructure:"prevent_destroy"`
+	CreateBeforeDestroy bool     `mapstructure:"create_before_destroy"`
+	IgnoreChanges       []string `mapstructure:"ignore_changes"`
+}
+
+var configFile string
+
+// config is the loaded configuration file, nil when there's none
+var config *runConfig
+
+// configErr is the error found loading the configuration file, the command fails with it before running
+var configErr error
+
+// loadConfig reads the configuration file, either the one given with --config or .gh-terraforming.yaml
+// in the working directory when it exists. The file provides the default value of every flag.
+func loadConfig(flags *pflag.FlagSet) error {
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName(configName)
+		viper.AddConfigPath(".")
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok && configFile == "" {
+			return nil
+		}
+		return err
+	}
+
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		// token and organization are bound to viper and read through it
+		if err != nil || flag.Changed || !viper.InConfig(flag.Name) || flag.Name == "token" || flag.Name == "organization" {
+			return
+		}
+		if setErr := flag.Value.Set(viper.GetString(flag.Name)); setErr != nil {
+			err = fmt.Errorf("%s: %v", flag.Name, setErr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	loaded := new(runConfig)
+	if err := viper.Unmarshal(loaded); err != nil {
+		return err
+	}
+
+	if err := loaded.validate(); err != nil {
+		return err
+	}
+
+	config = loaded
+
+	return nil
+}
+
+func (c *runConfig) validate() error {
+	switch c.Naming {
+	case "", "default", "snake_case":
+	default:
+		return fmt.Errorf("naming must be either default or snake_case, got %s", c.Naming)
+	}
+
+	switch c.Layout {
+	case "", "type", "generator", "single":
+	default:
+		return fmt.Errorf("layout must be either type, generator or single, got %s", c.Layout)
+	}
+
+	if _, err := selectGenerators(c.Generators); err != nil {
+		return err
+	}
+
+	resourceTypes := make(map[string]bool)
+	for _, g := range Generators() {
+		if source, ok := g.(templateSource); ok {
+			for name := range source.Templates() {
+				resourceTypes[name] = true
+			}
+		}
+	}
+
+	for resourceType, settings := range c.Resources {
+		if !resourceTypes[resourceType] {
+			return fmt.Errorf("unknown resource type %s", resourceType)
+		}
+		if settings == nil {
+			continue
+		}
+
+		for _, pattern := range append(settings.Include, settings.Exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: invalid pattern %s", resourceType, pattern)
+			}
+		}
+
+		for name, value := range settings.Attributes {
+			if _, err := configValue(value); err != nil {
+				return fmt.Errorf("%s: attribute %s: %v", resourceType, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resource returns the settings of the resource type, nil when it has none
+func (c *runConfig) resource(resourceType string) *resourceConfig {
+	if c == nil {
+		return nil
+	}
+
+	return c.Resources[resourceType]
+}
+
+// includes tells whether the resource passes the include and exclude filters of its type
+func (c *runConfig) includes(resource *Resource) bool {
+	if resource.Data == nil {
+		return true
+	}
+
+	return c.includesName(resource.Type, resource.Name)
+}
+
+// includesName reports whether the resource of the given type and name passes the filters
+func (c *runConfig) includesName(resourceType, name string) bool {
+	settings := c.resource(resourceType)
+	if settings == nil {
+		return true
+	}
+
+	if len(settings.Include) > 0 && !matchesAny(settings.Include, name) {
+		return false
+	}
+
+	return !matchesAny(settings.Exclude, name)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outputFile returns the file the resource is written to according to the layout
+func (c *runConfig) outputFile(g Generator, resource *Resource) string {
+	if settings := c.resource(resource.Type); settings != nil && settings.File != "" {
+		return settings.File
+	}
+
+	if c != nil {
+		switch c.Layout {
+		case "generator":
+			return fmt.Sprintf("%s.tf", g.Name())
+		case "single":
+			return "main.tf"
+		}
+	}
+
+	return g.OutputFile(resource)
+}
+
+// filterResources drops the resources excluded by the configuration
+func (s *session) filterResources(resources []*Resource) []*Resource {
+	if s.config == nil {
+		return resources
+	}
+
+	var filtered []*Resource
+	for _, resource := range resources {
+		if !s.config.includes(resource) {
+			s.log.WithFields(logrus.Fields{
+				"Type": resource.Type,
+				"Name": resource.Name,
+			}).Debug("Skipping filtered resource")
+			continue
+		}
+		filtered = append(filtered, resource)
+	}
+
+	return filtered
+}
+
+// applyResourceConfig adds the configured lifecycle and attributes to the rendered resource code, along
+// with the provider alias of multi-organization runs
+func (s *session) applyResourceConfig(resourceType string, code []byte) ([]byte, error) {
+	settings := s.config.resource(resourceType)
+	if settings == nil {
+		settings = new(resourceConfig)
+	}
+	if settings.Lifecycle == nil && len(settings.Attributes) == 0 && s.providerAlias == "" {
+		return code, nil
+	}
+
+	file, diags := hclwrite.ParseConfig(code, resourceType, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "resource" || len(block.Labels()) == 0 || block.Labels()[0] != resourceType {
+			continue
+		}
+
+		body := block.Body()
+
+		var names []string
+		for name := range settings.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value, err := configValue(settings.Attributes[name])
+			if err != nil {
+				return nil, err
+			}
+			body.SetAttributeValue(name, value)
+		}
+
+		if s.providerAlias != "" {
+			body.SetAttributeTraversal("provider", hcl.Traversal{
+				hcl.TraverseRoot{Name: "github"},
+				hcl.TraverseAttr{Name: s.providerAlias},
+			})
+		}
+
+		if lifecycle := settings.Lifecycle; lifecycle != nil {
+			lifecycleBody := lifecycleBlock(body)
+			if lifecycle.PreventDestroy {
+				lifecycleBody.SetAttributeValue("prevent_destroy", cty.True)
+			}
+			if lifecycle.CreateBeforeDestroy {
+				lifecycleBody.SetAttributeValue("create_before_destroy", cty.True)
+			}
+			if len(lifecycle.IgnoreChanges) > 0 {
+				ignored := lifecycle.IgnoreChanges
+				if existing := lifecycleBody.GetAttribute("ignore_changes"); existing != nil {
+					ignored = append(ignoreChangesNames(existing), ignored...)
+				}
+
+				tokens, err := ignoreChangesTokens(ignored)
+				if err != nil {
+					return nil, err
+				}
+				lifecycleBody.SetAttributeRaw("ignore_changes", tokens)
+			}
+		}
+	}
+
+	return hclwrite.Format(file.Bytes()), nil
+}
+
+// lifecycleBlock returns the body of the lifecycle block of the resource, the template may already write one
+func lifecycleBlock(body *hclwrite.Body) *hclwrite.Body {
+	for _, block := range body.Blocks() {
+		if block.Type() == "lifecycle" {
+			return block.Body()
+		}
+	}
+
+	body.AppendNewline()
+	return body.AppendNewBlock("lifecycle", nil).Body()
+}
+
+// ignoreChangesNames returns the attribute names of an ignore_changes written by a template
+func ignoreChangesNames(attribute *hclwrite.Attribute) []string {
+	expression := strings.TrimSpace(string(attribute.Expr().BuildTokens(nil).Bytes()))
+
+	var names []string
+	for _, name := range strings.Split(strings.Trim(expression, "[]"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// ignoreChangesTokens returns the ignore_changes expression, either all or a list of attribute names
+func ignoreChangesTokens(attributes []string) (hclwrite.Tokens, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, attribute := range attributes {
+		if attribute == "all" {
+			names = []string{"all"}
+			break
+		}
+		if !seen[attribute] {
+			seen[attribute] = true
+			names = append(names, attribute)
+		}
+	}
+
+	expression := "all"
+	if len(names) != 1 || names[0] != "all" {
+		expression = fmt.Sprintf("[%s]", strings.Join(names, ", "))
+	}
+
+	file, diags := hclwrite.ParseConfig([]byte(fmt.Sprintf("ignore_changes = %s\n", expression)), "ignore_changes", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return file.Body().GetAttribute("ignore_changes").Expr().BuildTokens(nil), nil
+}
+
+// configValue converts a value read from the configuration file into its HCL value
+func configValue(i interface{}) (cty.Value, error) {
+	switch v := i.(type) {
+	case string:
+		return cty.StringVal(v), nil
+	case bool:
+		return cty.BoolVal(v), nil
+	case int:
+		return cty.NumberIntVal(int64(v)), nil
+	case int64:
+		return cty.NumberIntVal(v), nil
+	case float64:
+		return cty.NumberFloatVal(v), nil
+	case []interface{}:
+		if len(v) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		var elems []cty.Value
+		for _, elem := range v {
+			value, err := configValue(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, value)
+		}
+		return cty.TupleVal(elems), nil
+	case map[string]interface{}:
+		attrs := make(map[string]cty.Value)
+		for key, elem := range v {
+			value, err := configValue(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = value
+		}
+		return cty.ObjectVal(attrs), nil
+	case map[interface{}]interface{}:
+		attrs := make(map[string]interface{})
+		for key, elem := range v {
+			attrs[fmt.Sprintf("%v", key)] = elem
+		}
+		return configValue(attrs)
+	default:
+		return cty.NilVal, fmt.Errorf("unsupported value %v", i)
+	}
+}
+
+var snakeCaseReplacer = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// snakeCaseName is the snake_case naming strategy, e.g. My-Repo.js becomes my_repo_js
+func snakeCaseName(name string) string {
+	return snakeCaseReplacer.ReplaceAllString(strings.ToLower(strings.Replace(name, "*", "star", -1)), "_")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestConfigValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    cty.Value
+		wantErr bool
+	}{
+		{name: "string", value: "main", want: cty.StringVal("main")},
+		{name: "bool", value: true, want: cty.True},
+		{name: "int", value: 3, want: cty.NumberIntVal(3)},
+		{name: "int64", value: int64(-3), want: cty.NumberIntVal(-3)},
+		{name: "float", value: 1.5, want: cty.NumberFloatVal(1.5)},
+		{name: "empty list", value: []interface{}{}, want: cty.EmptyTupleVal},
+		{name: "list", value: []interface{}{"a", 1}, want: cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.NumberIntVal(1)})},
+		{
+			name:  "map",
+			value: map[string]interface{}{"enabled": true, "branches": []interface{}{"main"}},
+			want:  cty.ObjectVal(map[string]cty.Value{"enabled": cty.True, "branches": cty.TupleVal([]cty.Value{cty.StringVal("main")})}),
+		},
+		{name: "yaml map", value: map[interface{}]interface{}{"count": 1}, want: cty.ObjectVal(map[string]cty.Value{"count": cty.NumberIntVal(1)})},
+		{name: "nil", value: nil, wantErr: true},
+		{name: "unsupported list element", value: []interface{}{struct{}{}}, wantErr: true},
+		{name: "unsupported map value", value: map[string]interface{}{"a": []string{"b"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := configValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("configValue(%#v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.RawEquals(tt.want) {
+				t.Errorf("configValue(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnakeCaseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "api", want: "api"},
+		{name: "My-Repo.js", want: "my_repo_js"},
+		{name: "type: bug", want: "type_bug"},
+		{name: "area/foo", want: "area_foo"},
+		{name: "release-*", want: "release_star"},
+		{name: "already_snake_case", want: "already_snake_case"},
+		{name: "Ünïcode", want: "_n_code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snakeCaseName(tt.name); got != tt.want {
+				t.Errorf("snakeCaseName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,559 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// snapshotDiff is the changelog between two snapshots of the same organization
+type snapshotDiff struct {
+	Organization string            `json:"organization"`
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	Changes      []*snapshotChange `json:"changes"`
+}
+
+// snapshotChange is a single entry of the changelog, only the fields relevant to its type are set
+type snapshotChange struct {
+	Type       string `json:"type"`
+	Repository string `json:"repository,omitempty"`
+	Team       string `json:"team,omitempty"`
+	User       string `json:"user,omitempty"`
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+	Message    string `json:"message"`
+}
+
+var diffFile string
+
+func init() {
+	rootCmd.AddCommand(diffSnapshotsCmd)
+
+	diffSnapshotsCmd.Flags().StringVarP(&diffFile, "file", "f", "", "Write the JSON changelog to this file (default to snapshot_diff.json in the output directory)")
+}
+
+var diffSnapshotsCmd = &cobra.Command{
+	Use:   "diff-snapshots OLD NEW",
+	Short: "Compare two snapshots and print what changed in the organization",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Debug("Comparing snapshots")
+
+		oldSnapshot, err := readSnapshot(args[0])
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		newSnapshot, err := readSnapshot(args[1])
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		if oldSnapshot.Organization != newSnapshot.Organization {
+			log.Errorf("the snapshots belong to different organizations, %s and %s", oldSnapshot.Organization, newSnapshot.Organization)
+			return
+		}
+
+		diff := diffSnapshots(oldSnapshot, newSnapshot)
+
+		path := diffFile
+		if path == "" {
+			path = filepath.Join(outDirectory, "snapshot_diff.json")
+		}
+
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			log.Error(err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"File":    path,
+			"Changes": len(diff.Changes),
+		}).Debug("Changelog saved")
+
+		snapshotDiffPrint(diff)
+	},
+}
+
+func snapshotDiffPrint(diff *snapshotDiff) {
+	fmt.Printf("Changes in the %s organization between %s and %s\n\n", diff.Organization, diff.From.Format(time.RFC3339), diff.To.Format(time.RFC3339))
+
+	if len(diff.Changes) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+
+	for _, change := range diff.Changes {
+		fmt.Printf("- %s\n", change.Message)
+	}
+}
+
+func diffSnapshots(oldSnapshot, newSnapshot *snapshot) *snapshotDiff {
+	diff := &snapshotDiff{
+		Organization: newSnapshot.Organization,
+		From:         oldSnapshot.CreatedAt,
+		To:           newSnapshot.CreatedAt,
+		Changes:      []*snapshotChange{},
+	}
+
+	diff.organization(oldSnapshot, newSnapshot)
+	diff.members(oldSnapshot, newSnapshot)
+	diff.repositories(oldSnapshot, newSnapshot)
+	diff.teams(oldSnapshot, newSnapshot)
+
+	return diff
+}
+
+func (d *snapshotDiff) add(change *snapshotChange) {
+	d.Changes = append(d.Changes, change)
+}
+
+// organization compares the base permission every member gets on the organization repositories
+func (d *snapshotDiff) organization(oldSnapshot, newSnapshot *snapshot) {
+	before := apiPermission(oldSnapshot.Org.GetDefaultRepoPermission())
+	after := apiPermission(newSnapshot.Org.GetDefaultRepoPermission())
+
+	// the organization settings weren't read in one of the snapshots
+	if before == "" || after == "" {
+		return
+	}
+
+	change := &snapshotChange{
+		Before: before,
+		After:  after,
+	}
+
+	switch {
+	case permissionRank(after) > permissionRank(before):
+		change.Type = "default_repository_permission_escalated"
+		change.Message = fmt.Sprintf("the base permission of the organization members was raised from %s to %s", before, after)
+	case permissionRank(after) < permissionRank(before):
+		change.Type = "default_repository_permission_reduced"
+		change.Message = fmt.Sprintf("the base permission of the organization members was lowered from %s to %s", before, after)
+	default:
+		return
+	}
+
+	d.add(change)
+}
+
+// members compares the organization members and their roles
+func (d *snapshotDiff) members(oldSnapshot, newSnapshot *snapshot) {
+	oldRoles := snapshotUserRoles(oldSnapshot.Members)
+	newRoles := snapshotUserRoles(newSnapshot.Members)
+
+	for _, login := range sortedKeys(newRoles) {
+		role := newRoles[login]
+		oldRole, ok := oldRoles[login]
+
+		switch {
+		case !ok:
+			d.add(&snapshotChange{
+				Type:    "member_added",
+				User:    login,
+				After:   role,
+				Message: fmt.Sprintf("%s joined the organization as %s", login, role),
+			})
+		case oldRole != role:
+			d.add(&snapshotChange{
+				Type:    "member_role_changed",
+				User:    login,
+				Before:  oldRole,
+				After:   role,
+				Message: fmt.Sprintf("%s organization role changed from %s to %s", login, oldRole, role),
+			})
+		}
+	}
+
+	for _, login := range sortedKeys(oldRoles) {
+		if _, ok := newRoles[login]; !ok {
+			d.add(&snapshotChange{
+				Type:    "member_removed",
+				User:    login,
+				Before:  oldRoles[login],
+				Message: fmt.Sprintf("%s left the organization", login),
+			})
+		}
+	}
+}
+
+// repositories compares the repositories, matched by ID so renames are detected, along with their collaborators and webhooks
+func (d *snapshotDiff) repositories(oldSnapshot, newSnapshot *snapshot) {
+	oldRepos := make(map[int64]*snapshotRepository)
+	for _, r := range oldSnapshot.Repositories {
+		oldRepos[r.Repository.GetID()] = r
+	}
+
+	newRepos := make(map[int64]*snapshotRepository)
+	for _, r := range sortedSnapshotRepositories(newSnapshot.Repositories) {
+		repo := r.Repository
+		newRepos[repo.GetID()] = r
+
+		old, ok := oldRepos[repo.GetID()]
+		if !ok {
+			d.add(&snapshotChange{
+				Type:       "repository_created",
+				Repository: repo.GetName(),
+				After:      repositoryVisibility(repo),
+				Message:    fmt.Sprintf("repository %s was created as %s", repo.GetName(), repositoryVisibility(repo)),
+			})
+			old = &snapshotRepository{
+				Repository:    &github.Repository{},
+				Collaborators: map[string][]*github.User{"direct": {}},
+			}
+		} else {
+			d.repository(old.Repository, repo)
+		}
+
+		d.collaborators(repo.GetName(), old, r)
+		d.webhooks(repo.GetName(), old, r)
+	}
+
+	for _, r := range sortedSnapshotRepositories(oldSnapshot.Repositories) {
+		if _, ok := newRepos[r.Repository.GetID()]; !ok {
+			d.add(&snapshotChange{
+				Type:       "repository_deleted",
+				Repository: r.Repository.GetName(),
+				Message:    fmt.Sprintf("repository %s was deleted or transferred", r.Repository.GetName()),
+			})
+		}
+	}
+}
+
+func (d *snapshotDiff) repository(oldRepo, newRepo *github.Repository) {
+	if oldRepo.GetName() != newRepo.GetName() {
+		d.add(&snapshotChange{
+			Type:       "repository_renamed",
+			Repository: newRepo.GetName(),
+			Before:     oldRepo.GetName(),
+			After:      newRepo.GetName(),
+			Message:    fmt.Sprintf("repository %s was renamed to %s", oldRepo.GetName(), newRepo.GetName()),
+		})
+	}
+
+	if oldRepo.GetArchived() != newRepo.GetArchived() {
+		change := &snapshotChange{
+			Type:       "repository_archived",
+			Repository: newRepo.GetName(),
+			Message:    fmt.Sprintf("repository %s was archived", newRepo.GetName()),
+		}
+		if !newRepo.GetArchived() {
+			change.Type = "repository_unarchived"
+			change.Message = fmt.Sprintf("repository %s was unarchived", newRepo.GetName())
+		}
+		d.add(change)
+	}
+
+	if before, after := repositoryVisibility(oldRepo), repositoryVisibility(newRepo); before != after {
+		d.add(&snapshotChange{
+			Type:       "repository_visibility_changed",
+			Repository: newRepo.GetName(),
+			Before:     before,
+			After:      after,
+			Message:    fmt.Sprintf("repository %s visibility changed from %s to %s", newRepo.GetName(), before, after),
+		})
+	}
+}
+
+// collaborators compares the direct collaborators, skipping the repositories whose collaborators couldn't be
+// read in either snapshot, otherwise they'd all show up as added or removed
+func (d *snapshotDiff) collaborators(repoName string, oldRepo, newRepo *snapshotRepository) {
+	if oldRepo.Collaborators["direct"] == nil || newRepo.Collaborators["direct"] == nil {
+		return
+	}
+
+	oldPermissions := snapshotUserPermissions(oldRepo.Collaborators["direct"])
+	newPermissions := snapshotUserPermissions(newRepo.Collaborators["direct"])
+	outside := snapshotUserPermissions(newRepo.Collaborators["outside"])
+
+	for _, login := range sortedKeys(newPermissions) {
+		permission := newPermissions[login]
+		oldPermission, ok := oldPermissions[login]
+
+		change := &snapshotChange{
+			Repository: repoName,
+			User:       login,
+			Before:     oldPermission,
+			After:      permission,
+		}
+
+		switch {
+		case !ok:
+			change.Type = "collaborator_added"
+			change.Message = fmt.Sprintf("%s was added as a collaborator to %s with %s permission", login, repoName, permission)
+			if _, ok := outside[login]; ok {
+				change.Type = "outside_collaborator_added"
+				change.Message = fmt.Sprintf("%s was added as an outside collaborator to %s with %s permission", login, repoName, permission)
+			}
+		case permissionRank(permission) > permissionRank(oldPermission):
+			change.Type = "collaborator_permission_escalated"
+			change.Message = fmt.Sprintf("%s permission on %s was raised from %s to %s", login, repoName, oldPermission, permission)
+		case permissionRank(permission) < permissionRank(oldPermission):
+			change.Type = "collaborator_permission_reduced"
+			change.Message = fmt.Sprintf("%s permission on %s was lowered from %s to %s", login, repoName, oldPermission, permission)
+		default:
+			continue
+		}
+
+		d.add(change)
+	}
+
+	for _, login := range sortedKeys(oldPermissions) {
+		if _, ok := newPermissions[login]; !ok {
+			d.add(&snapshotChange{
+				Type:       "collaborator_removed",
+				Repository: repoName,
+				User:       login,
+				Before:     oldPermissions[login],
+				Message:    fmt.Sprintf("%s is no longer a collaborator of %s", login, repoName),
+			})
+		}
+	}
+}
+
+func (d *snapshotDiff) webhooks(repoName string, oldRepo, newRepo *snapshotRepository) {
+	oldHooks := make(map[int64]*github.Hook)
+	for _, hook := range oldRepo.Webhooks {
+		oldHooks[hook.GetID()] = hook
+	}
+
+	newHooks := make(map[int64]*github.Hook)
+	for _, hook := range newRepo.Webhooks {
+		newHooks[hook.GetID()] = hook
+
+		if _, ok := oldHooks[hook.GetID()]; !ok {
+			d.add(&snapshotChange{
+				Type:       "webhook_added",
+				Repository: repoName,
+				After:      webhookURL(hook),
+				Message:    fmt.Sprintf("webhook to %s was added to %s", webhookURL(hook), repoName),
+			})
+		}
+	}
+
+	for _, hook := range oldRepo.Webhooks {
+		if _, ok := newHooks[hook.GetID()]; !ok {
+			d.add(&snapshotChange{
+				Type:       "webhook_removed",
+				Repository: repoName,
+				Before:     webhookURL(hook),
+				Message:    fmt.Sprintf("webhook to %s was removed from %s", webhookURL(hook), repoName),
+			})
+		}
+	}
+}
+
+// teams compares the teams, matched by ID so renames are detected, along with their members and repositories
+func (d *snapshotDiff) teams(oldSnapshot, newSnapshot *snapshot) {
+	oldTeams := make(map[int64]*snapshotTeam)
+	for _, t := range oldSnapshot.Teams {
+		oldTeams[t.Team.GetID()] = t
+	}
+
+	newTeams := make(map[int64]*snapshotTeam)
+	for _, t := range sortedSnapshotTeams(newSnapshot.Teams) {
+		team := t.Team
+		newTeams[team.GetID()] = t
+
+		old, ok := oldTeams[team.GetID()]
+		if !ok {
+			d.add(&snapshotChange{
+				Type:    "team_created",
+				Team:    team.GetName(),
+				Message: fmt.Sprintf("team %s was created", team.GetName()),
+			})
+			old = &snapshotTeam{Team: &github.Team{}}
+		} else if old.Team.GetName() != team.GetName() {
+			d.add(&snapshotChange{
+				Type:    "team_renamed",
+				Team:    team.GetName(),
+				Before:  old.Team.GetName(),
+				After:   team.GetName(),
+				Message: fmt.Sprintf("team %s was renamed to %s", old.Team.GetName(), team.GetName()),
+			})
+		}
+
+		d.teamMembers(team.GetName(), old, t)
+		d.teamRepositories(team.GetName(), old, t)
+	}
+
+	for _, t := range sortedSnapshotTeams(oldSnapshot.Teams) {
+		if _, ok := newTeams[t.Team.GetID()]; !ok {
+			d.add(&snapshotChange{
+				Type:    "team_deleted",
+				Team:    t.Team.GetName(),
+				Message: fmt.Sprintf("team %s was deleted", t.Team.GetName()),
+			})
+		}
+	}
+}
+
+func (d *snapshotDiff) teamMembers(teamName string, oldTeam, newTeam *snapshotTeam) {
+	oldRoles := snapshotUserRoles(oldTeam.Members)
+	newRoles := snapshotUserRoles(newTeam.Members)
+
+	for _, login := range sortedKeys(newRoles) {
+		role := newRoles[login]
+		oldRole, ok := oldRoles[login]
+
+		switch {
+		case !ok:
+			d.add(&snapshotChange{
+				Type:    "team_member_added",
+				Team:    teamName,
+				User:    login,
+				After:   role,
+				Message: fmt.Sprintf("%s was added to team %s as %s", login, teamName, role),
+			})
+		case oldRole != role:
+			d.add(&snapshotChange{
+				Type:    "team_member_role_changed",
+				Team:    teamName,
+				User:    login,
+				Before:  oldRole,
+				After:   role,
+				Message: fmt.Sprintf("%s role in team %s changed from %s to %s", login, teamName, oldRole, role),
+			})
+		}
+	}
+
+	for _, login := range sortedKeys(oldRoles) {
+		if _, ok := newRoles[login]; !ok {
+			d.add(&snapshotChange{
+				Type:    "team_member_removed",
+				Team:    teamName,
+				User:    login,
+				Before:  oldRoles[login],
+				Message: fmt.Sprintf("%s was removed from team %s", login, teamName),
+			})
+		}
+	}
+}
+
+func (d *snapshotDiff) teamRepositories(teamName string, oldTeam, newTeam *snapshotTeam) {
+	oldPermissions := make(map[int64]string)
+	for _, repo := range oldTeam.Repositories {
+		oldPermissions[repo.GetID()] = highestPermission(repo.GetPermissions())
+	}
+
+	newPermissions := make(map[int64]string)
+	for _, repo := range newTeam.Repositories {
+		permission := highestPermission(repo.GetPermissions())
+		newPermissions[repo.GetID()] = permission
+		oldPermission, ok := oldPermissions[repo.GetID()]
+
+		change := &snapshotChange{
+			Team:       teamName,
+			Repository: repo.GetName(),
+			Before:     oldPermission,
+			After:      permission,
+		}
+
+		switch {
+		case !ok:
+			change.Type = "team_repository_added"
+			change.Message = fmt.Sprintf("team %s was granted %s access to %s", teamName, permission, repo.GetName())
+		case permissionRank(permission) > permissionRank(oldPermission):
+			change.Type = "team_repository_permission_escalated"
+			change.Message = fmt.Sprintf("team %s access to %s was raised from %s to %s", teamName, repo.GetName(), oldPermission, permission)
+		case permissionRank(permission) < permissionRank(oldPermission):
+			change.Type = "team_repository_permission_reduced"
+			change.Message = fmt.Sprintf("team %s access to %s was lowered from %s to %s", teamName, repo.GetName(), oldPermission, permission)
+		default:
+			continue
+		}
+
+		d.add(change)
+	}
+
+	for _, repo := range oldTeam.Repositories {
+		if _, ok := newPermissions[repo.GetID()]; !ok {
+			d.add(&snapshotChange{
+				Type:       "team_repository_removed",
+				Team:       teamName,
+				Repository: repo.GetName(),
+				Before:     oldPermissions[repo.GetID()],
+				Message:    fmt.Sprintf("team %s no longer has access to %s", teamName, repo.GetName()),
+			})
+		}
+	}
+}
+
+// snapshotUserRoles turns users indexed by role into the role of every user indexed by login
+func snapshotUserRoles(usersByRole map[string][]*github.User) map[string]string {
+	roles := make(map[string]string)
+	for role, users := range usersByRole {
+		for _, user := range users {
+			roles[user.GetLogin()] = role
+		}
+	}
+
+	return roles
+}
+
+// snapshotUserPermissions returns the highest permission of every user indexed by login
+func snapshotUserPermissions(users []*github.User) map[string]string {
+	permissions := make(map[string]string)
+	for _, user := range users {
+		permissions[user.GetLogin()] = highestPermission(user.GetPermissions())
+	}
+
+	return permissions
+}
+
+// repositoryVisibility falls back to the private flag for repositories fetched without the visibility
+func repositoryVisibility(repo *github.Repository) string {
+	if repo.GetVisibility() != "" {
+		return repo.GetVisibility()
+	}
+	if repo.GetPrivate() {
+		return "private"
+	}
+
+	return "public"
+}
+
+func webhookURL(hook *github.Hook) string {
+	url, _ := hook.Config["url"].(string)
+	return url
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedSnapshotRepositories(repos []*snapshotRepository) []*snapshotRepository {
+	sorted := append([]*snapshotRepository{}, repos...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Repository.GetName() < sorted[j].Repository.GetName() })
+
+	return sorted
+}
+
+func sortedSnapshotTeams(teams []*snapshotTeam) []*snapshotTeam {
+	sorted := append([]*snapshotTeam{}, teams...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Team.GetName() < sorted[j].Team.GetName() })
+
+	return sorted
+}
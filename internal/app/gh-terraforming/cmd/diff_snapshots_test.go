@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func testUser(login, permission string) *github.User {
+	user := &github.User{Login: github.String(login)}
+	if permission != "" {
+		user.Permissions = &map[string]bool{permission: true}
+	}
+
+	return user
+}
+
+func testRepository(id int64, name, permission string) *github.Repository {
+	repo := &github.Repository{ID: github.Int64(id), Name: github.String(name), Private: github.Bool(true)}
+	if permission != "" {
+		repo.Permissions = &map[string]bool{permission: true}
+	}
+
+	return repo
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new *snapshot
+		want     []string
+	}{
+		{
+			name: "no changes",
+			old:  &snapshot{Members: map[string][]*github.User{"admin": {testUser("alice", "")}}},
+			new:  &snapshot{Members: map[string][]*github.User{"admin": {testUser("alice", "")}}},
+			want: []string{},
+		},
+		{
+			name: "members",
+			old: &snapshot{Members: map[string][]*github.User{
+				"admin":  {testUser("alice", "")},
+				"member": {testUser("bob", "")},
+			}},
+			new: &snapshot{Members: map[string][]*github.User{
+				"admin":  {testUser("bob", "")},
+				"member": {testUser("carol", "")},
+			}},
+			want: []string{"member_role_changed", "member_added", "member_removed"},
+		},
+		{
+			name: "repositories",
+			old: &snapshot{Repositories: []*snapshotRepository{
+				{Repository: testRepository(1, "api", "")},
+				{Repository: testRepository(2, "web", "")},
+			}},
+			new: &snapshot{Repositories: []*snapshotRepository{
+				{Repository: &github.Repository{ID: github.Int64(1), Name: github.String("api-v2"), Archived: github.Bool(true), Private: github.Bool(false)}},
+				{Repository: testRepository(3, "docs", "")},
+			}},
+			want: []string{"repository_renamed", "repository_archived", "repository_visibility_changed", "repository_created", "repository_deleted"},
+		},
+		{
+			name: "collaborators and webhooks",
+			old: &snapshot{Repositories: []*snapshotRepository{{
+				Repository: testRepository(1, "api", ""),
+				Collaborators: map[string][]*github.User{
+					"direct": {testUser("alice", "pull"), testUser("bob", "admin"), testUser("carol", "push")},
+				},
+				Webhooks: []*github.Hook{{ID: github.Int64(10), Config: map[string]interface{}{"url": "https://old"}}},
+			}}},
+			new: &snapshot{Repositories: []*snapshotRepository{{
+				Repository: testRepository(1, "api", ""),
+				Collaborators: map[string][]*github.User{
+					"direct":  {testUser("alice", "admin"), testUser("bob", "push"), testUser("dave", "pull"), testUser("erin", "pull")},
+					"outside": {testUser("erin", "pull")},
+				},
+				Webhooks: []*github.Hook{{ID: github.Int64(11)}},
+			}}},
+			want: []string{
+				"collaborator_permission_escalated", "collaborator_permission_reduced", "collaborator_added",
+				"outside_collaborator_added", "collaborator_removed", "webhook_added", "webhook_removed",
+			},
+		},
+		{
+			name: "organization",
+			old:  &snapshot{Org: &github.Organization{DefaultRepoPermission: github.String("read")}},
+			new:  &snapshot{Org: &github.Organization{DefaultRepoPermission: github.String("write")}},
+			want: []string{"default_repository_permission_escalated"},
+		},
+		{
+			name: "unreadable collaborators",
+			old: &snapshot{Repositories: []*snapshotRepository{{
+				Repository:    testRepository(1, "api", ""),
+				Collaborators: map[string][]*github.User{"direct": {testUser("alice", "pull")}},
+			}}},
+			new: &snapshot{Repositories: []*snapshotRepository{{
+				Repository:    testRepository(1, "api", ""),
+				Collaborators: map[string][]*github.User{"direct": nil, "outside": nil},
+			}}},
+			want: []string{},
+		},
+		{
+			name: "teams",
+			old: &snapshot{Teams: []*snapshotTeam{
+				{
+					Team:         &github.Team{ID: github.Int64(1), Name: github.String("core")},
+					Members:      map[string][]*github.User{"member": {testUser("alice", ""), testUser("bob", "")}},
+					Repositories: []*github.Repository{testRepository(1, "api", "pull"), testRepository(2, "web", "admin"), testRepository(3, "docs", "push")},
+				},
+				{Team: &github.Team{ID: github.Int64(2), Name: github.String("ops")}},
+			}},
+			new: &snapshot{Teams: []*snapshotTeam{
+				{
+					Team:         &github.Team{ID: github.Int64(1), Name: github.String("platform")},
+					Members:      map[string][]*github.User{"maintainer": {testUser("alice", "")}, "member": {testUser("carol", "")}},
+					Repositories: []*github.Repository{testRepository(1, "api", "push"), testRepository(2, "web", "pull"), testRepository(4, "cli", "pull")},
+				},
+				{Team: &github.Team{ID: github.Int64(3), Name: github.String("security")}},
+			}},
+			want: []string{
+				"team_renamed", "team_member_role_changed", "team_member_added", "team_member_removed",
+				"team_repository_permission_escalated", "team_repository_permission_reduced", "team_repository_added",
+				"team_repository_removed", "team_created", "team_deleted",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffSnapshots(tt.old, tt.new)
+
+			got := []string{}
+			for _, change := range diff.Changes {
+				got = append(got, change.Type)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffSnapshots() changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
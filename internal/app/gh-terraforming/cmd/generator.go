@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Resource is a single Terraform resource produced by a generator
+type Resource struct {
+	// Type is the Terraform resource type, e.g. github_repository
+	Type string
+	// Name is the Terraform resource name
+	Name string
+	// ImportID is the ID given to terraform import, empty when the resource can't be imported
+	ImportID string
+	// Data is passed to the resource type template
+	Data interface{}
+	// Comment is written instead of the resource when there's no Data, to explain why something was skipped
+	Comment string
+}
+
+// Generator turns one kind of Github object into Terraform resources
+type Generator interface {
+	// Name is the command name of the generator, e.g. repository
+	Name() string
+	// Fetch gets the data from Github and returns the resources to generate
+	Fetch(s *session) ([]*Resource, error)
+	// Render writes the HCL code of the resource
+	Render(s *session, resource *Resource, output io.Writer) error
+	// OutputFile is the file name the resource is written to
+	OutputFile(resource *Resource) string
+	// ImportID is the ID given to terraform import for the resource
+	ImportID(resource *Resource) string
+}
+
+// generators holds every registered generator indexed by name
+var generators = make(map[string]Generator)
+
+// RegisterGenerator adds the generator to the registry and returns the command running it
+func RegisterGenerator(g Generator, short string) *cobra.Command {
+	if _, ok := generators[g.Name()]; ok {
+		panic(fmt.Sprintf("generator %s is already registered", g.Name()))
+	}
+	generators[g.Name()] = g
+
+	cmd := &cobra.Command{
+		Use:   g.Name(),
+		Short: short,
+		Run: func(cmd *cobra.Command, args []string) {
+			if userMode && organizationOnly(g) {
+				log.Errorf("%s requires an organization, it can't be used with --user", g.Name())
+				return
+			}
+
+			forEachOrganization(func(s *session) {
+				s.generators = generatorNames([]Generator{g})
+				s.runGenerator(g)
+			})
+		},
+	}
+	rootCmd.AddCommand(cmd)
+
+	return cmd
+}
+
+// Generators returns the registered generators sorted by name
+func Generators() []Generator {
+	var all []Generator
+	for _, g := range generators {
+		all = append(all, g)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+
+	return all
+}
+
+// generatorNames indexes the names of the generators
+func generatorNames(gs []Generator) map[string]bool {
+	names := make(map[string]bool)
+	for _, g := range gs {
+		names[g.Name()] = true
+	}
+
+	return names
+}
+
+// generates reports whether the resource of the given type and name is generated in the same run by the
+// generator, so other resources can reference it instead of repeating its ID
+func (s *session) generates(generator, resourceType, name string) bool {
+	return s.generators[generator] && (s.config == nil || s.config.includesName(resourceType, name))
+}
+
+// runGenerator fetches the generator resources and writes each one of them to its output file
+func (s *session) runGenerator(g Generator) {
+	s.log.WithFields(logrus.Fields{
+		"Generator": g.Name(),
+	}).Debug("Running generator")
+
+	resources, err := g.Fetch(s)
+	if err != nil {
+		return
+	}
+
+	if err := s.writeResources(g, resources); err != nil {
+		s.log.Error(err)
+	}
+}
+
+// writeResources writes the resources to their output files
+func (s *session) writeResources(g Generator, resources []*Resource) error {
+	resources = s.filterResources(resources)
+	if len(resources) == 0 {
+		s.log.WithFields(logrus.Fields{
+			"Generator": g.Name(),
+		}).Info("Nothing found")
+		return nil
+	}
+
+	files, err := s.renderResources(g, resources)
+	if err != nil {
+		return err
+	}
+
+	if err := s.writeProvider(); err != nil {
+		return err
+	}
+
+	for file, content := range files {
+		if err := s.writeOutputFile(filepath.Join(s.outDirectory, file), content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderResource renders the HCL code of the resource with the configuration file settings applied
+func (s *session) renderResource(g Generator, resource *Resource) ([]byte, error) {
+	var code bytes.Buffer
+	if err := g.Render(s, resource, &code); err != nil {
+		return nil, err
+	}
+
+	return s.applyResourceConfig(resource.Type, code.Bytes())
+}
+
+// writeOutputFile creates the file the first time it's written during the run and appends to it afterwards,
+// as layouts may write resources of several generators to the same file. JSON files are merged instead.
+func (s *session) writeOutputFile(path string, content []byte) error {
+	if !s.writtenFiles[path] {
+		s.writtenFiles[path] = true
+		return ioutil.WriteFile(path, content, 0666)
+	}
+
+	if strings.HasSuffix(path, ".json") {
+		merged, err := mergeJSONFile(path, content)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, merged, 0666)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// renderResources renders the resources indexed by output file, in the order they were fetched
+func (s *session) renderResources(g Generator, resources []*Resource) (map[string][]byte, error) {
+	if s.outputFormat == "json" {
+		return s.renderJSONResources(g, resources)
+	}
+
+	outputs := make(map[string]*bytes.Buffer)
+	for _, resource := range resources {
+		file := s.config.outputFile(g, resource)
+
+		output, ok := outputs[file]
+		if !ok {
+			output = new(bytes.Buffer)
+			outputs[file] = output
+		}
+
+		content, err := s.renderResource(g, resource)
+		if err != nil {
+			return nil, err
+		}
+		output.Write(content)
+	}
+
+	files := make(map[string][]byte)
+	for file, output := range outputs {
+		files[file] = output.Bytes()
+	}
+
+	return files, nil
+}
+
+// generator implements Generator for the resources rendered with the templates of this package
+type generator struct {
+	name string
+	// fetch returns the resources to generate
+	fetch func(s *session) ([]*Resource, error)
+	// templates holds the template of every resource type the generator produces
+	templates map[string]string
+	// shared holds the templates defined for every resource template to use, indexed by name
+	shared map[string]string
+	// outputFile overrides the default output file, named after the resource type
+	outputFile func(resource *Resource) string
+	// organizationOnly is set for the generators that have nothing to generate for personal accounts
+	organizationOnly bool
+}
+
+func (g *generator) Name() string {
+	return g.name
+}
+
+func (g *generator) Fetch(s *session) ([]*Resource, error) {
+	return g.fetch(s)
+}
+
+func (g *generator) Render(s *session, resource *Resource, output io.Writer) error {
+	if resource.Data == nil {
+		_, err := fmt.Fprintf(output, "\n# %s\n", resource.Comment)
+		return err
+	}
+
+	builtin, ok := g.templates[resource.Type]
+	if !ok {
+		return fmt.Errorf("generator %s has no template for %s", g.name, resource.Type)
+	}
+
+	text, err := s.loadTemplate(resource.Type, builtin)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(resource.Type).Funcs(s.templateFuncs()).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	for name, builtin := range g.shared {
+		text, err := s.loadTemplate(name, builtin)
+		if err != nil {
+			return err
+		}
+		if _, err := tmpl.Parse(text); err != nil {
+			return err
+		}
+	}
+
+	return executeTemplate(tmpl, output, resource.Data)
+}
+
+// OrganizationOnly tells whether the generator only applies to organizations
+func (g *generator) OrganizationOnly() bool {
+	return g.organizationOnly
+}
+
+// Templates returns the built-in templates of the generator indexed by name
+func (g *generator) Templates() map[string]string {
+	templates := make(map[string]string)
+	for name, text := range g.templates {
+		templates[name] = text
+	}
+	for name, text := range g.shared {
+		templates[name] = text
+	}
+
+	return templates
+}
+
+func (g *generator) OutputFile(resource *Resource) string {
+	if g.outputFile != nil {
+		if file := g.outputFile(resource); file != "" {
+			return file
+		}
+	}
+
+	return fmt.Sprintf("%s.tf", resource.Type)
+}
+
+func (g *generator) ImportID(resource *Resource) string {
+	return resource.ImportID
+}
+
+// organizationOnly tells whether the generator only applies to organizations, generators that don't say
+// are assumed to support personal accounts
+func organizationOnly(g Generator) bool {
+	if o, ok := g.(interface{ OrganizationOnly() bool }); ok {
+		return o.OrganizationOnly()
+	}
+
+	return false
+}
+
+// commentResource explains in the generated code why something was skipped, it's written along the resources of the given type
+func commentResource(resourceType, format string, a ...interface{}) *Resource {
+	return &Resource{Type: resourceType, Comment: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,711 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// graphQLResponse is the envelope of every response returned by the Github GraphQL API
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+// graphQLError is an error returned by the Github GraphQL API, Path locates the field that failed
+type graphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+func (e graphQLError) Error() string {
+	return e.Message
+}
+
+// queryGraphQL runs a GraphQL query through the same client used for the REST API and decodes
+// the response data into result. Some settings, like team code review assignment, are only
+// exposed through GraphQL.
+func (s *session) queryGraphQL(query string, variables map[string]interface{}, result interface{}) error {
+	errs, err := s.queryGraphQLPartial(query, variables, result)
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// queryGraphQLPartial runs a GraphQL query that may partially fail, the fields that failed are null in
+// result and their errors are returned
+func (s *session) queryGraphQLPartial(query string, variables map[string]interface{}, result interface{}) ([]graphQLError, error) {
+	req, err := s.api.NewRequest("POST", "graphql", map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp graphQLResponse
+	if _, err := s.api.Do(s.ctx, req, &resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) == 0 || string(resp.Data) == "null" {
+		if len(resp.Errors) > 0 {
+			return nil, resp.Errors[0]
+		}
+		return nil, errors.New("empty GraphQL response")
+	}
+
+	return resp.Errors, json.Unmarshal(resp.Data, result)
+}
+
+// The GraphQL backend, selected with --api graphql, implements the same fetch functions as the REST
+// API but gets the repositories and teams along with their branches, labels, collaborators, members
+// and repositories in a few paginated queries. The results are cached so every generator in the same
+// run shares them. Nested lists bigger than a single page, and data that isn't available through
+// GraphQL like webhooks, are still fetched with the REST API.
+
+const graphqlPageSize = 100
+
+const graphqlRepositoriesQuery = `
+query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    repositories(first: 50, after: $cursor) {
+      nodes {
+        databaseId
+        name
+        description
+        homepageUrl
+        url
+        createdAt
+        pushedAt
+        visibility
+        isFork
+        isPrivate
+        isArchived
+        isTemplate
+        hasIssuesEnabled
+        hasProjectsEnabled
+        hasWikiEnabled
+        mergeCommitAllowed
+        squashMergeAllowed
+        rebaseMergeAllowed
+        deleteBranchOnMerge
+        defaultBranchRef { name }
+        repositoryTopics(first: 100) { nodes { topic { name } } }
+        refs(refPrefix: "refs/heads/", first: 100) {
+          nodes { name branchProtectionRule { id } }
+          pageInfo { hasNextPage }
+        }
+        labels(first: 100) {
+          nodes { name color description }
+          pageInfo { hasNextPage }
+        }
+        direct: collaborators(first: 100, affiliation: DIRECT) {
+          edges { permission node { login } }
+          pageInfo { hasNextPage }
+        }
+        outside: collaborators(first: 100, affiliation: OUTSIDE) {
+          edges { permission node { login } }
+          pageInfo { hasNextPage }
+        }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}
+`
+
+const graphqlTeamsQuery = `
+query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    teams(first: 50, after: $cursor) {
+      nodes {
+        databaseId
+        name
+        slug
+        description
+        privacy
+        parentTeam { databaseId }
+        members(first: 100, membership: ALL) {
+          edges { role node { login } }
+          pageInfo { hasNextPage }
+        }
+        immediate: members(first: 100, membership: IMMEDIATE) {
+          edges { role node { login } }
+          pageInfo { hasNextPage }
+        }
+        repositories(first: 100) {
+          edges { permission node { databaseId name } }
+          pageInfo { hasNextPage }
+        }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}
+`
+
+const graphqlTeamImmediateMembersQuery = `
+query($org: String!, $team: String!, $cursor: String) {
+  organization(login: $org) {
+    team(slug: $team) {
+      members(first: 100, after: $cursor, membership: IMMEDIATE) {
+        edges { role node { login } }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+}
+`
+
+const graphqlMembersQuery = `
+query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    membersWithRole(first: 100, after: $cursor) {
+      edges { role node { login } }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}
+`
+
+type graphqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphqlUserEdges struct {
+	Edges []struct {
+		Role       string `json:"role"`
+		Permission string `json:"permission"`
+		Node       struct {
+			Login string `json:"login"`
+		} `json:"node"`
+	} `json:"edges"`
+	PageInfo graphqlPageInfo `json:"pageInfo"`
+}
+
+type graphqlRepository struct {
+	DatabaseID          int64             `json:"databaseId"`
+	Name                string            `json:"name"`
+	Description         string            `json:"description"`
+	HomepageURL         string            `json:"homepageUrl"`
+	URL                 string            `json:"url"`
+	CreatedAt           *github.Timestamp `json:"createdAt"`
+	PushedAt            *github.Timestamp `json:"pushedAt"`
+	Visibility          string            `json:"visibility"`
+	IsFork              bool              `json:"isFork"`
+	IsPrivate           bool              `json:"isPrivate"`
+	IsArchived          bool              `json:"isArchived"`
+	IsTemplate          bool              `json:"isTemplate"`
+	HasIssuesEnabled    bool              `json:"hasIssuesEnabled"`
+	HasProjectsEnabled  bool              `json:"hasProjectsEnabled"`
+	HasWikiEnabled      bool              `json:"hasWikiEnabled"`
+	MergeCommitAllowed  bool              `json:"mergeCommitAllowed"`
+	SquashMergeAllowed  bool              `json:"squashMergeAllowed"`
+	RebaseMergeAllowed  bool              `json:"rebaseMergeAllowed"`
+	DeleteBranchOnMerge bool              `json:"deleteBranchOnMerge"`
+	DefaultBranchRef    *struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	Refs struct {
+		Nodes []struct {
+			Name                 string    `json:"name"`
+			BranchProtectionRule *struct{} `json:"branchProtectionRule"`
+		} `json:"nodes"`
+		PageInfo graphqlPageInfo `json:"pageInfo"`
+	} `json:"refs"`
+	Labels struct {
+		Nodes    []*github.Label `json:"nodes"`
+		PageInfo graphqlPageInfo `json:"pageInfo"`
+	} `json:"labels"`
+	Direct  graphqlUserEdges `json:"direct"`
+	Outside graphqlUserEdges `json:"outside"`
+	// CollaboratorsUnreadable is true when the token can't read the collaborators of the repository
+	CollaboratorsUnreadable bool `json:"-"`
+}
+
+type graphqlTeam struct {
+	DatabaseID  int64  `json:"databaseId"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Privacy     string `json:"privacy"`
+	ParentTeam  *struct {
+		DatabaseID int64 `json:"databaseId"`
+	} `json:"parentTeam"`
+	Members      graphqlUserEdges `json:"members"`
+	Immediate    graphqlUserEdges `json:"immediate"`
+	Repositories struct {
+		Edges []struct {
+			Permission string `json:"permission"`
+			Node       struct {
+				DatabaseID int64  `json:"databaseId"`
+				Name       string `json:"name"`
+			} `json:"node"`
+		} `json:"edges"`
+		PageInfo graphqlPageInfo `json:"pageInfo"`
+	} `json:"repositories"`
+}
+
+// graphqlPermission converts the GraphQL repository permissions to the permissions map returned by the REST API
+func graphqlPermission(permission string) *map[string]bool {
+	permissions := map[string]bool{
+		"ADMIN":    permission == "ADMIN",
+		"MAINTAIN": permission == "MAINTAIN",
+		"WRITE":    permission == "WRITE",
+		"TRIAGE":   permission == "TRIAGE",
+		"READ":     permission == "READ",
+	}
+
+	return &map[string]bool{
+		"admin":    permissions["ADMIN"],
+		"maintain": permissions["MAINTAIN"],
+		"push":     permissions["WRITE"],
+		"triage":   permissions["TRIAGE"],
+		"pull":     permissions["READ"],
+	}
+}
+
+// graphqlPaginate runs a query for every page of an organization connection, page receives each response,
+// along with the errors of the fields that failed, and returns the page info of the paginated connection.
+// The query gets the organization and the cursor along with the given variables.
+func (s *session) graphqlPaginate(query string, variables map[string]interface{}, page func(data json.RawMessage, errs []graphQLError) (graphqlPageInfo, error)) error {
+	var cursor *string
+	for {
+		vars := map[string]interface{}{"org": s.orgName, "cursor": cursor}
+		for name, value := range variables {
+			vars[name] = value
+		}
+
+		var data json.RawMessage
+		errs, err := s.queryGraphQLPartial(query, vars, &data)
+		if err != nil {
+			s.log.Error(err)
+			return err
+		}
+
+		pageInfo, err := page(data, errs)
+		if err != nil {
+			s.log.Error(err)
+			return err
+		}
+
+		if !pageInfo.HasNextPage {
+			return nil
+		}
+		cursor = &pageInfo.EndCursor
+		s.log.Debugf("Fetching next page %s", *cursor)
+	}
+}
+
+// graphqlCollaboratorsError returns the repository whose collaborators failed with the error, nil when the
+// error is about something else. Reading the collaborators requires push access to the repository.
+func graphqlCollaboratorsError(e graphQLError, repositories []*graphqlRepository) *graphqlRepository {
+	// organization.repositories.nodes[i].direct or outside
+	if len(e.Path) != 5 || e.Path[0] != "organization" || e.Path[1] != "repositories" || e.Path[2] != "nodes" {
+		return nil
+	}
+	if e.Path[4] != "direct" && e.Path[4] != "outside" {
+		return nil
+	}
+
+	i, ok := e.Path[3].(float64)
+	if !ok || int(i) < 0 || int(i) >= len(repositories) || repositories[int(i)] == nil {
+		return nil
+	}
+
+	return repositories[int(i)]
+}
+
+func (s *session) graphqlFetchRepositories() error {
+	if s.graphqlRepositories != nil {
+		return nil
+	}
+
+	repositories := make(map[string]*graphqlRepository)
+	err := s.graphqlPaginate(graphqlRepositoriesQuery, nil, func(data json.RawMessage, errs []graphQLError) (graphqlPageInfo, error) {
+		var result struct {
+			Organization struct {
+				Repositories struct {
+					Nodes    []*graphqlRepository `json:"nodes"`
+					PageInfo graphqlPageInfo      `json:"pageInfo"`
+				} `json:"repositories"`
+			} `json:"organization"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return graphqlPageInfo{}, err
+		}
+
+		for _, e := range errs {
+			repo := graphqlCollaboratorsError(e, result.Organization.Repositories.Nodes)
+			if repo == nil {
+				return graphqlPageInfo{}, e
+			}
+
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.Name,
+				"Error":      e.Message,
+			}).Warn("Can't read the repository collaborators, skipping them")
+			repo.CollaboratorsUnreadable = true
+		}
+
+		for _, repo := range result.Organization.Repositories.Nodes {
+			repositories[repo.Name] = repo
+		}
+
+		return result.Organization.Repositories.PageInfo, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.graphqlRepositories = repositories
+	return nil
+}
+
+func (s *session) graphqlFetchTeams() error {
+	if s.graphqlTeams != nil {
+		return nil
+	}
+
+	teams := make(map[string]*graphqlTeam)
+	err := s.graphqlPaginate(graphqlTeamsQuery, nil, func(data json.RawMessage, errs []graphQLError) (graphqlPageInfo, error) {
+		if len(errs) > 0 {
+			return graphqlPageInfo{}, errs[0]
+		}
+
+		var result struct {
+			Organization struct {
+				Teams struct {
+					Nodes    []*graphqlTeam  `json:"nodes"`
+					PageInfo graphqlPageInfo `json:"pageInfo"`
+				} `json:"teams"`
+			} `json:"organization"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return graphqlPageInfo{}, err
+		}
+
+		for _, team := range result.Organization.Teams.Nodes {
+			teams[team.Slug] = team
+		}
+
+		return result.Organization.Teams.PageInfo, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.graphqlTeams = teams
+	return nil
+}
+
+func (s *session) graphqlGetRepositories() ([]*github.Repository, error) {
+	if err := s.graphqlFetchRepositories(); err != nil {
+		return nil, err
+	}
+
+	var allRepos []*github.Repository
+	for _, repo := range s.graphqlRepositories {
+		r := &github.Repository{
+			ID:                  github.Int64(repo.DatabaseID),
+			Name:                github.String(repo.Name),
+			Description:         github.String(repo.Description),
+			Homepage:            github.String(repo.HomepageURL),
+			HTMLURL:             github.String(repo.URL),
+			CreatedAt:           repo.CreatedAt,
+			PushedAt:            repo.PushedAt,
+			Visibility:          github.String(strings.ToLower(repo.Visibility)),
+			Fork:                github.Bool(repo.IsFork),
+			Private:             github.Bool(repo.IsPrivate),
+			Archived:            github.Bool(repo.IsArchived),
+			IsTemplate:          github.Bool(repo.IsTemplate),
+			HasIssues:           github.Bool(repo.HasIssuesEnabled),
+			HasProjects:         github.Bool(repo.HasProjectsEnabled),
+			HasWiki:             github.Bool(repo.HasWikiEnabled),
+			AllowMergeCommit:    github.Bool(repo.MergeCommitAllowed),
+			AllowSquashMerge:    github.Bool(repo.SquashMergeAllowed),
+			AllowRebaseMerge:    github.Bool(repo.RebaseMergeAllowed),
+			DeleteBranchOnMerge: github.Bool(repo.DeleteBranchOnMerge),
+		}
+		if repo.DefaultBranchRef != nil {
+			r.DefaultBranch = github.String(repo.DefaultBranchRef.Name)
+		}
+		for _, topic := range repo.RepositoryTopics.Nodes {
+			r.Topics = append(r.Topics, topic.Topic.Name)
+		}
+
+		allRepos = append(allRepos, r)
+	}
+
+	// keep the same order as the REST API
+	sort.Slice(allRepos, func(i, j int) bool { return allRepos[i].GetID() < allRepos[j].GetID() })
+
+	return allRepos, nil
+}
+
+// graphqlGetRepositoryBranches returns false when the branches aren't cached and need to be fetched with REST
+func (s *session) graphqlGetRepositoryBranches(repo *github.Repository) ([]*github.Branch, bool, error) {
+	if err := s.graphqlFetchRepositories(); err != nil {
+		return nil, false, err
+	}
+
+	cached, ok := s.graphqlRepositories[repo.GetName()]
+	if !ok || cached.Refs.PageInfo.HasNextPage {
+		return nil, false, nil
+	}
+
+	var branches []*github.Branch
+	for _, ref := range cached.Refs.Nodes {
+		protected := ref.BranchProtectionRule != nil
+		if s.branchProtectedOnly && !protected {
+			continue
+		}
+
+		branches = append(branches, &github.Branch{Name: github.String(ref.Name), Protected: github.Bool(protected)})
+	}
+
+	return branches, true, nil
+}
+
+// graphqlGetRepositoryIssueLabels returns false when the labels aren't cached and need to be fetched with REST
+func (s *session) graphqlGetRepositoryIssueLabels(repo *github.Repository) ([]*github.Label, bool, error) {
+	if err := s.graphqlFetchRepositories(); err != nil {
+		return nil, false, err
+	}
+
+	cached, ok := s.graphqlRepositories[repo.GetName()]
+	if !ok || cached.Labels.PageInfo.HasNextPage {
+		return nil, false, nil
+	}
+
+	return cached.Labels.Nodes, true, nil
+}
+
+// graphqlGetRepositoryCollaborators returns false when the collaborators aren't cached and need to be fetched with REST,
+// and errCollaboratorsUnreadable when the token can't read them
+func (s *session) graphqlGetRepositoryCollaborators(repo *github.Repository, affiliation string) ([]*github.User, bool, error) {
+	if err := s.graphqlFetchRepositories(); err != nil {
+		return nil, false, err
+	}
+
+	cached, ok := s.graphqlRepositories[repo.GetName()]
+	if !ok {
+		return nil, false, nil
+	}
+
+	// the warning was logged when fetching the repositories
+	if cached.CollaboratorsUnreadable {
+		return nil, true, errCollaboratorsUnreadable
+	}
+
+	edges := cached.Direct
+	if affiliation == "outside" {
+		edges = cached.Outside
+	}
+	if edges.PageInfo.HasNextPage {
+		return nil, false, nil
+	}
+
+	collaborators := make([]*github.User, 0, len(edges.Edges))
+	for _, edge := range edges.Edges {
+		collaborators = append(collaborators, &github.User{
+			Login:       github.String(edge.Node.Login),
+			Permissions: graphqlPermission(edge.Permission),
+		})
+	}
+
+	return collaborators, true, nil
+}
+
+func (s *session) graphqlGetOrgTeams() ([]*github.Team, error) {
+	if err := s.graphqlFetchTeams(); err != nil {
+		return nil, err
+	}
+
+	var allTeams []*github.Team
+	for _, team := range s.graphqlTeams {
+		t := &github.Team{
+			ID:          github.Int64(team.DatabaseID),
+			Name:        github.String(team.Name),
+			Slug:        github.String(team.Slug),
+			Description: github.String(team.Description),
+			Privacy:     github.String("secret"),
+		}
+		// the REST API calls visible teams closed
+		if team.Privacy == "VISIBLE" {
+			t.Privacy = github.String("closed")
+		}
+		if team.ParentTeam != nil {
+			t.Parent = &github.Team{ID: github.Int64(team.ParentTeam.DatabaseID)}
+		}
+
+		allTeams = append(allTeams, t)
+	}
+
+	// keep the same order as the REST API
+	sort.Slice(allTeams, func(i, j int) bool { return allTeams[i].GetName() < allTeams[j].GetName() })
+
+	return allTeams, nil
+}
+
+// graphqlGetOrgTeamMemberships returns false when the members aren't cached and need to be fetched with REST
+func (s *session) graphqlGetOrgTeamMemberships(team *github.Team, role string) ([]*github.User, bool, error) {
+	if err := s.graphqlFetchTeams(); err != nil {
+		return nil, false, err
+	}
+
+	cached, ok := s.graphqlTeams[team.GetSlug()]
+	if !ok || cached.Members.PageInfo.HasNextPage {
+		return nil, false, nil
+	}
+
+	var members []*github.User
+	for _, edge := range cached.Members.Edges {
+		if role != "all" && !strings.EqualFold(edge.Role, role) {
+			continue
+		}
+
+		members = append(members, &github.User{Login: github.String(edge.Node.Login)})
+	}
+
+	return members, true, nil
+}
+
+// graphqlGetOrgTeamImmediateMembers returns the members of the team itself, leaving out the members of its
+// child teams. The REST API can't tell them apart, so this is used with both backends: the teams cached by
+// the GraphQL backend are used when they hold every immediate member, otherwise the team is queried alone.
+func (s *session) graphqlGetOrgTeamImmediateMembers(team *github.Team) (map[string]bool, error) {
+	members := make(map[string]bool)
+
+	if cached, ok := s.graphqlTeams[team.GetSlug()]; ok && !cached.Immediate.PageInfo.HasNextPage {
+		for _, edge := range cached.Immediate.Edges {
+			members[edge.Node.Login] = true
+		}
+		return members, nil
+	}
+
+	err := s.graphqlPaginate(graphqlTeamImmediateMembersQuery, map[string]interface{}{"team": team.GetSlug()}, func(data json.RawMessage, errs []graphQLError) (graphqlPageInfo, error) {
+		if len(errs) > 0 {
+			return graphqlPageInfo{}, errs[0]
+		}
+
+		var result struct {
+			Organization struct {
+				Team *struct {
+					Members graphqlUserEdges `json:"members"`
+				} `json:"team"`
+			} `json:"organization"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return graphqlPageInfo{}, err
+		}
+		if result.Organization.Team == nil {
+			return graphqlPageInfo{}, fmt.Errorf("team %s not found", team.GetSlug())
+		}
+
+		for _, edge := range result.Organization.Team.Members.Edges {
+			members[edge.Node.Login] = true
+		}
+
+		return result.Organization.Team.Members.PageInfo, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// graphqlGetOrgTeamRepositories returns false when the repositories aren't cached and need to be fetched with REST
+func (s *session) graphqlGetOrgTeamRepositories(team *github.Team) ([]*github.Repository, bool, error) {
+	if err := s.graphqlFetchTeams(); err != nil {
+		return nil, false, err
+	}
+
+	cached, ok := s.graphqlTeams[team.GetSlug()]
+	if !ok || cached.Repositories.PageInfo.HasNextPage {
+		return nil, false, nil
+	}
+
+	var repos []*github.Repository
+	for _, edge := range cached.Repositories.Edges {
+		repos = append(repos, &github.Repository{
+			ID:          github.Int64(edge.Node.DatabaseID),
+			Name:        github.String(edge.Node.Name),
+			Permissions: graphqlPermission(edge.Permission),
+		})
+	}
+
+	return repos, true, nil
+}
+
+// graphqlFetchMembers gets every member of the organization along with their role, graphqlGetOrgMembers
+// filters them so the members are only listed once for every role
+func (s *session) graphqlFetchMembers() error {
+	if s.graphqlMembers != nil {
+		return nil
+	}
+
+	members := &graphqlUserEdges{}
+	err := s.graphqlPaginate(graphqlMembersQuery, nil, func(data json.RawMessage, errs []graphQLError) (graphqlPageInfo, error) {
+		if len(errs) > 0 {
+			return graphqlPageInfo{}, errs[0]
+		}
+
+		var result struct {
+			Organization struct {
+				MembersWithRole graphqlUserEdges `json:"membersWithRole"`
+			} `json:"organization"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return graphqlPageInfo{}, err
+		}
+
+		members.Edges = append(members.Edges, result.Organization.MembersWithRole.Edges...)
+
+		return result.Organization.MembersWithRole.PageInfo, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.graphqlMembers = members
+	return nil
+}
+
+func (s *session) graphqlGetOrgMembers(role string) ([]*github.User, error) {
+	if err := s.graphqlFetchMembers(); err != nil {
+		return nil, err
+	}
+
+	var allMembers []*github.User
+	for _, edge := range s.graphqlMembers.Edges {
+		if role != "all" && !strings.EqualFold(edge.Role, role) {
+			continue
+		}
+
+		allMembers = append(allMembers, &github.User{Login: github.String(edge.Node.Login)})
+	}
+
+	return allMembers, nil
+}
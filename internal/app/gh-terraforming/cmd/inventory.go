@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// inventory is the normalized organization data, every list is a table of the CSV export
+type inventory struct {
+	Organization         string                          `json:"organization"`
+	CreatedAt            time.Time                       `json:"created_at"`
+	Repositories         []*inventoryRepository          `json:"repositories"`
+	Teams                []*inventoryTeam                `json:"teams"`
+	Members              []*inventoryMember              `json:"members"`
+	OutsideCollaborators []*inventoryOutsideCollaborator `json:"outside_collaborators"`
+	TeamRepositories     []*inventoryTeamRepository      `json:"team_repositories"`
+	Webhooks             []*inventoryWebhook             `json:"webhooks"`
+}
+
+type inventoryRepository struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	Visibility    string     `json:"visibility"`
+	Archived      bool       `json:"archived"`
+	Fork          bool       `json:"fork"`
+	DefaultBranch string     `json:"default_branch"`
+	Description   string     `json:"description"`
+	CreatedAt     *time.Time `json:"created_at"`
+	PushedAt      *time.Time `json:"pushed_at"`
+	URL           string     `json:"url"`
+}
+
+type inventoryTeam struct {
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Privacy     string `json:"privacy"`
+	Parent      string `json:"parent"`
+	Description string `json:"description"`
+}
+
+type inventoryMember struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+type inventoryOutsideCollaborator struct {
+	Repository string `json:"repository"`
+	Login      string `json:"login"`
+	Permission string `json:"permission"`
+}
+
+type inventoryTeamRepository struct {
+	Team       string `json:"team"`
+	Repository string `json:"repository"`
+	Permission string `json:"permission"`
+	// InheritedFrom is the ancestor team the grant comes from, empty for direct grants
+	InheritedFrom string `json:"inherited_from"`
+}
+
+type inventoryWebhook struct {
+	Repository  string   `json:"repository"`
+	ID          int64    `json:"id"`
+	URL         string   `json:"url"`
+	ContentType string   `json:"content_type"`
+	Active      bool     `json:"active"`
+	InsecureSSL bool     `json:"insecure_ssl"`
+	Events      []string `json:"events"`
+}
+
+func init() {
+	rootCmd.AddCommand(inventoryCmd)
+}
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Export the organization repositories, teams, members, outside collaborators, team grants and webhooks as JSON and CSV",
+	Long: `Export a normalized inventory of the organization for audits.
+
+  Writes inventory.json and one inventory_<table>.csv file per table to the output directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if userMode {
+			log.Error("inventory requires an organization, it can't be used with --user")
+			return
+		}
+
+		forEachOrganization(func(s *session) {
+			s.log.Debug("Getting inventory data")
+
+			inv, err := s.getInventory()
+			if err != nil {
+				s.log.Error(err)
+				return
+			}
+
+			if err := writeInventory(inv, s.outDirectory); err != nil {
+				s.log.Error(err)
+				return
+			}
+
+			s.log.WithFields(logrus.Fields{
+				"Directory":    s.outDirectory,
+				"Repositories": len(inv.Repositories),
+				"Teams":        len(inv.Teams),
+				"Members":      len(inv.Members),
+			}).Info("Inventory saved")
+		})
+	},
+}
+
+func (s *session) getInventory() (*inventory, error) {
+	// empty tables are written as empty lists rather than null
+	inv := &inventory{
+		Organization:         s.orgName,
+		CreatedAt:            time.Now().UTC(),
+		Repositories:         []*inventoryRepository{},
+		Teams:                []*inventoryTeam{},
+		Members:              []*inventoryMember{},
+		OutsideCollaborators: []*inventoryOutsideCollaborator{},
+		TeamRepositories:     []*inventoryTeamRepository{},
+		Webhooks:             []*inventoryWebhook{},
+	}
+	if s.archive != nil {
+		inv.CreatedAt = s.archive.CreatedAt
+	}
+
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, repo := range repos {
+		s.log.WithFields(logrus.Fields{
+			"Repository": repo.GetName(),
+		}).Debug("Processing repository inventory")
+
+		inv.Repositories = append(inv.Repositories, &inventoryRepository{
+			ID:            repo.GetID(),
+			Name:          repo.GetName(),
+			Visibility:    repositoryVisibility(repo),
+			Archived:      repo.GetArchived(),
+			Fork:          repo.GetFork(),
+			DefaultBranch: repo.GetDefaultBranch(),
+			Description:   repo.GetDescription(),
+			CreatedAt:     timestampTime(repo.CreatedAt),
+			PushedAt:      timestampTime(repo.PushedAt),
+			URL:           repo.GetHTMLURL(),
+		})
+
+		collaborators, err := s.getOrgRepositoryCollaborators(repo, "outside")
+		if err != nil && err != errCollaboratorsUnreadable {
+			return nil, err
+		}
+		for _, collaborator := range collaborators {
+			inv.OutsideCollaborators = append(inv.OutsideCollaborators, &inventoryOutsideCollaborator{
+				Repository: repo.GetName(),
+				Login:      collaborator.GetLogin(),
+				Permission: highestPermission(collaborator.GetPermissions()),
+			})
+		}
+
+		webhooks, err := s.getRepositoryWebhooks(repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, webhook := range webhooks {
+			contentType, _ := webhook.Config["content_type"].(string)
+			inv.Webhooks = append(inv.Webhooks, &inventoryWebhook{
+				Repository:  repo.GetName(),
+				ID:          webhook.GetID(),
+				URL:         webhookURL(webhook),
+				ContentType: contentType,
+				Active:      webhook.GetActive(),
+				InsecureSSL: fmt.Sprintf("%v", webhook.Config["insecure_ssl"]) == "1",
+				Events:      webhook.Events,
+			})
+		}
+	}
+
+	roles, err := s.getOrgMemberRoles()
+	if err != nil {
+		return nil, err
+	}
+	for _, login := range sortedKeys(roles) {
+		inv.Members = append(inv.Members, &inventoryMember{Login: login, Role: roles[login]})
+	}
+
+	teams, err := s.getOrgTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := newTeamHierarchy(teams)
+	grants, err := s.getOrgTeamsRepositories(teams)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range teams {
+		s.log.WithFields(logrus.Fields{
+			"Team": team.GetName(),
+		}).Debug("Processing team inventory")
+
+		inv.Teams = append(inv.Teams, &inventoryTeam{
+			ID:          team.GetID(),
+			Slug:        team.GetSlug(),
+			Name:        team.GetName(),
+			Privacy:     team.GetPrivacy(),
+			Parent:      hierarchy.parent(team).GetSlug(),
+			Description: team.GetDescription(),
+		})
+
+		for _, repo := range grants[team.GetID()] {
+			permission := highestPermission(repo.GetPermissions())
+			if permission == "" {
+				continue
+			}
+
+			grant := &inventoryTeamRepository{
+				Team:       team.GetSlug(),
+				Repository: repo.GetName(),
+				Permission: permission,
+			}
+			if parent := grants.inheritedFrom(team, repo, permission, hierarchy); parent != nil {
+				grant.InheritedFrom = parent.GetSlug()
+			}
+			inv.TeamRepositories = append(inv.TeamRepositories, grant)
+		}
+	}
+
+	return inv, nil
+}
+
+// writeInventory writes the inventory as JSON and every table of it as CSV
+func writeInventory(inv *inventory, dir string) error {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "inventory.json"), data, 0644); err != nil {
+		return err
+	}
+
+	tables := map[string]interface{}{
+		"repositories":          inv.Repositories,
+		"teams":                 inv.Teams,
+		"members":               inv.Members,
+		"outside_collaborators": inv.OutsideCollaborators,
+		"team_repositories":     inv.TeamRepositories,
+		"webhooks":              inv.Webhooks,
+	}
+
+	var names []string
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := writeCSV(filepath.Join(dir, fmt.Sprintf("inventory_%s.csv", name)), tables[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeCSV writes a slice of struct pointers as CSV, with a column per field named after its JSON name
+func writeCSV(path string, records interface{}) error {
+	rows := reflect.ValueOf(records)
+	recordType := rows.Type().Elem().Elem()
+
+	var header []string
+	for i := 0; i < recordType.NumField(); i++ {
+		header = append(header, strings.Split(recordType.Field(i).Tag.Get("json"), ",")[0])
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(header)
+	for i := 0; i < rows.Len(); i++ {
+		record := rows.Index(i).Elem()
+
+		var row []string
+		for j := 0; j < record.NumField(); j++ {
+			row = append(row, csvValue(record.Field(j).Interface()))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// timestampTime returns nil for unknown timestamps, e.g. the last push of an empty repository
+func timestampTime(ts *github.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+
+	return &ts.Time
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ";")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
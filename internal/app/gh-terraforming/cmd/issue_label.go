@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+const issueLabelTemplate = `
+{{- if hasLeadingDigit .RepoName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}-{{normalizeResourceName .Label.Name}}
+{{- end}}
+# terraform import github_issue_label.{{normalizeResourceName .RepoName}}-{{normalizeResourceName .Label.Name}} {{.RepoName}}:{{.Label.Name}}
+resource "github_issue_label" "{{normalizeResourceName .RepoName}}-{{normalizeResourceName .Label.Name}}" {
+  repository  = {{hclValue .RepoName}}
+  name        = {{hclValue .Label.Name}}
+  color       = {{hclValue .Label.Color}}
+  {{- attr "description" .Label.Description ""}}
+}
+`
+
+const issueLabelsTemplate = `
+{{- if hasLeadingDigit .RepoName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}
+{{- end}}
+# terraform import github_issue_labels.{{normalizeResourceName .RepoName}} {{.RepoName}}
+resource "github_issue_labels" "{{normalizeResourceName .RepoName}}" {
+  repository = {{hclValue .RepoName}}
+  {{- range .Labels}}
+
+  label {
+    name        = {{hclValue .GetName}}
+    color       = {{hclValue .GetColor}}
+    {{- attr "description" .GetDescription ""}}
+  }
+  {{- end}}
+}
+`
+
+// defaultIssueLabels is the label set Github creates on every new repository,
+// indexed by name with the color and description it is created with
+var defaultIssueLabels = map[string][2]string{
+	"bug":              {"d73a4a", "Something isn't working"},
+	"documentation":    {"0075ca", "Improvements or additions to documentation"},
+	"duplicate":        {"cfd3d7", "This issue or pull request already exists"},
+	"enhancement":      {"a2eeef", "New feature or request"},
+	"good first issue": {"7057ff", "Good for newcomers"},
+	"help wanted":      {"008672", "Extra attention is needed"},
+	"invalid":          {"e4e669", "This doesn't seem right"},
+	"question":         {"d876e3", "Further information is requested"},
+	"wontfix":          {"ffffff", "This will not be worked on"},
+}
+
+var issueLabelSkipDefaults bool
+
+var issueLabelCmd = RegisterGenerator(&generator{
+	name:  "issue-label",
+	fetch: (*session).issueLabelFetch,
+	templates: map[string]string{
+		"github_issue_label":  issueLabelTemplate,
+		"github_issue_labels": issueLabelsTemplate,
+	},
+}, "Import repository issue labels into Terraform")
+
+func init() {
+	issueLabelCmd.Flags().BoolVar(&issueLabelSkipDefaults, "skip-default-labels", false, "Omit labels that are unchanged from Github's default label set, can't be used with --authoritative")
+}
+
+func (s *session) issueLabelFetch() ([]*Resource, error) {
+	s.log.Debug("Getting issue labels data")
+
+	// github_issue_labels deletes every label it doesn't list, the default labels included
+	if s.authoritative && s.issueLabelSkipDefaults {
+		err := fmt.Errorf("--skip-default-labels can't be used with --authoritative, github_issue_labels would delete the default labels")
+		s.log.Error(err)
+		return nil, err
+	}
+
+	// first get repositories, then for each repo, get its labels
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, repo := range repos {
+
+		labels, err := s.getRepositoryIssueLabels(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.issueLabelSkipDefaults {
+			var customLabels []*github.Label
+			for _, label := range labels {
+				if !isDefaultIssueLabel(label) {
+					customLabels = append(customLabels, label)
+				}
+			}
+			labels = customLabels
+		}
+
+		if s.authoritative {
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+			}).Debug("Processing issue labels")
+
+			resources = append(resources, s.issueLabelsResource(repo, labels))
+			continue
+		}
+
+		for _, label := range labels {
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+				"Label":      label.GetName(),
+			}).Debug("Processing issue label")
+
+			resources = append(resources, s.issueLabelResource(repo, label))
+		}
+	}
+
+	return resources, nil
+}
+
+func (s *session) getRepositoryIssueLabels(repo *github.Repository) ([]*github.Label, error) {
+	if s.archive != nil {
+		return s.archive.repository(repo).Labels, nil
+	}
+
+	if s.apiBackend == "graphql" {
+		if result, ok, err := s.graphqlGetRepositoryIssueLabels(repo); err != nil || ok {
+			return result, err
+		}
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+
+	var allLabels []*github.Label
+	for {
+		labels, resp, err := s.api.Issues.ListLabels(s.ctx, s.orgName, repo.GetName(), opt)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		allLabels = append(allLabels, labels...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	return allLabels, nil
+}
+
+// isDefaultIssueLabel reports whether the label is one of Github's default labels
+// left untouched, a renamed or recolored default label is treated as a custom one
+func isDefaultIssueLabel(label *github.Label) bool {
+	defaults, ok := defaultIssueLabels[strings.ToLower(label.GetName())]
+	if !ok {
+		return false
+	}
+
+	return strings.EqualFold(label.GetColor(), defaults[0]) && label.GetDescription() == defaults[1]
+}
+
+// IssueLabelData is passed to the github_issue_label template
+type IssueLabelData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// Label is the Github issue label
+	Label github.Label
+}
+
+func (s *session) issueLabelResource(repo *github.Repository, label *github.Label) *Resource {
+	return &Resource{
+		Type:     "github_issue_label",
+		Name:     fmt.Sprintf("%s-%s", s.normalizeResourceName(repo.GetName()), s.normalizeResourceName(label.GetName())),
+		ImportID: fmt.Sprintf("%s:%s", repo.GetName(), label.GetName()),
+		Data: IssueLabelData{
+			Org:      s.orgName,
+			RepoName: repo.GetName(),
+			Label:    *label,
+		},
+	}
+}
+
+// IssueLabelsData is passed to the github_issue_labels template
+type IssueLabelsData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// Labels are the Github issue labels of the repository
+	Labels []*github.Label
+}
+
+func (s *session) issueLabelsResource(repo *github.Repository, labels []*github.Label) *Resource {
+	return &Resource{
+		Type:     "github_issue_labels",
+		Name:     s.normalizeResourceName(repo.GetName()),
+		ImportID: repo.GetName(),
+		Data: IssueLabelsData{
+			Org:      s.orgName,
+			RepoName: repo.GetName(),
+			Labels:   labels,
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// GenerateOptions configures a Generate call
+type GenerateOptions struct {
+	// Generators are the names of the generators to run, e.g. repository or team-membership.
+	// Every registered generator runs when empty.
+	Generators []string
+	// Authoritative emits one authoritative resource per repository or team instead of one resource per item
+	Authoritative bool
+	// API is the Github API used to fetch the data, rest or graphql. Defaults to rest.
+	API string
+	// Format is the format of the generated files, hcl or json (Terraform JSON with import blocks). Defaults to hcl.
+	Format string
+	// TemplateDir holds the <resource type>.tmpl files overriding the built-in templates, none when empty
+	TemplateDir string
+	// Logger receives the log messages, nothing is logged when nil
+	Logger *logrus.Logger
+
+	// BranchDefaultOnly only exports the default branch of each repository with repository-branch
+	BranchDefaultOnly bool
+	// BranchProtectedOnly only exports protected branches with repository-branch
+	BranchProtectedOnly bool
+	// BranchPattern only exports the branches whose name matches this regular expression with repository-branch
+	BranchPattern string
+	// SkipDefaultLabels omits the labels unchanged from Github's default label set with issue-label,
+	// it can't be used with Authoritative
+	SkipDefaultLabels bool
+}
+
+// Generated holds the resources produced by a generator
+type Generated struct {
+	// Generator is the name of the generator
+	Generator string
+	// Resources are the generated resources in the order they were fetched
+	Resources []*Resource
+	// Files holds the HCL code of the resources indexed by output file name
+	Files map[string][]byte
+}
+
+// Generate runs the generators against the organization with the given client and returns what they
+// produced, without writing any file. Every call has its own state, it's safe to use from several goroutines.
+func Generate(c context.Context, client *github.Client, org string, opts GenerateOptions) ([]*Generated, error) {
+	if client == nil {
+		return nil, fmt.Errorf("a Github client is required")
+	}
+	if org == "" {
+		return nil, fmt.Errorf("an organization is required")
+	}
+
+	backend := opts.API
+	if backend == "" {
+		backend = "rest"
+	}
+	if backend != "rest" && backend != "graphql" {
+		return nil, fmt.Errorf("API must be either rest or graphql, got %s", backend)
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = "hcl"
+	}
+	if format != "hcl" && format != "json" {
+		return nil, fmt.Errorf("format must be either hcl or json, got %s", format)
+	}
+
+	selected, err := selectGenerators(opts.Generators)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetOutput(ioutil.Discard)
+	}
+
+	s := &session{
+		ctx:                    c,
+		api:                    client,
+		log:                    logger,
+		orgName:                org,
+		apiBackend:             backend,
+		authoritative:          opts.Authoritative,
+		outputFormat:           format,
+		templateDirectory:      opts.TemplateDir,
+		branchDefaultOnly:      opts.BranchDefaultOnly,
+		branchProtectedOnly:    opts.BranchProtectedOnly,
+		branchPattern:          opts.BranchPattern,
+		issueLabelSkipDefaults: opts.SkipDefaultLabels,
+		generators:             generatorNames(selected),
+		writtenFiles:           make(map[string]bool),
+	}
+
+	var results []*Generated
+	for _, g := range selected {
+		resources, err := g.Fetch(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", g.Name(), err)
+		}
+
+		files, err := s.renderResources(g, resources)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", g.Name(), err)
+		}
+
+		results = append(results, &Generated{
+			Generator: g.Name(),
+			Resources: resources,
+			Files:     files,
+		})
+	}
+
+	return results, nil
+}
+
+// selectGenerators returns the registered generators with the given names, all of them when names is empty
+func selectGenerators(names []string) ([]Generator, error) {
+	if len(names) == 0 {
+		return Generators(), nil
+	}
+
+	var selected []Generator
+	for _, name := range names {
+		g, ok := generators[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown generator %s", name)
+		}
+		selected = append(selected, g)
+	}
+
+	return selected, nil
+}
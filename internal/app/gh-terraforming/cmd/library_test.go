@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectGenerators(t *testing.T) {
+	var all []string
+	for _, g := range Generators() {
+		all = append(all, g.Name())
+	}
+
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", names: nil, want: all},
+		{name: "single", names: []string{"team"}, want: []string{"team"}},
+		{name: "given order", names: []string{"team-repository", "repository"}, want: []string{"team-repository", "repository"}},
+		{name: "unknown", names: []string{"repository", "repositories"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectGenerators(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectGenerators(%v) error = %v, wantErr %v", tt.names, err, tt.wantErr)
+			}
+
+			var got []string
+			for _, g := range selected {
+				got = append(got, g.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectGenerators(%v) = %v, want %v", tt.names, got, tt.want)
+			}
+		})
+	}
+}
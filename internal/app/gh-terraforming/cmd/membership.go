@@ -2,12 +2,9 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"text/template"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const membershipTemplate = `
@@ -15,55 +12,99 @@ const membershipTemplate = `
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
 # Suggestion: use this identifier instead _{{normalizeResourceName .Username}}
 {{- end}}
-# terraform import github_membership.{{normalizeResourceName .Username}} {{.Org}}:{{normalizeResourceName .Username}}
+{{- if .Pending}}
+# NOTE this is a pending invitation that hasn't been accepted yet, please review it
+{{- end}}
+# terraform import github_membership.{{normalizeResourceName .Username}} {{.Org}}:{{.Username}}
 resource "github_membership" "{{normalizeResourceName .Username}}" {
-  username = "{{.Username}}"
-  role     = "{{.Role}}"
+  username = {{hclValue .Username}}
+  {{- attr "role" .Role "member"}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(membershipCmd)
-}
+var membershipCmd = RegisterGenerator(&generator{
+	name:  "membership",
+	fetch: (*session).membershipFetch,
+	templates: map[string]string{
+		"github_membership": membershipTemplate,
+	},
+	organizationOnly: true,
+}, "Import organization members into Terraform")
 
-var membershipCmd = &cobra.Command{
-	Use:   "membership",
-	Short: "Import organization members into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting membership data")
+func (s *session) membershipFetch() ([]*Resource, error) {
+	s.log.Debug("Getting membership data")
 
-		members, err := getOrgMembers()
-		if err != nil {
-			return
+	// Listing the members filtered by role gives us their role without one extra request per member
+	roles, err := s.getOrgMemberRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, login := range sortedKeys(roles) {
+		s.log.WithFields(logrus.Fields{
+			"Member": login,
+			"Role":   roles[login],
+		}).Debug("Processing membership")
+
+		resources = append(resources, s.membershipResource(login, roles[login], false))
+	}
+
+	invitations, err := s.getOrgPendingInvitations()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invitation := range invitations {
+		s.log.WithFields(logrus.Fields{
+			"Login": invitation.GetLogin(),
+			"Email": invitation.GetEmail(),
+			"Role":  invitation.GetRole(),
+		}).Debug("Processing pending invitation")
+
+		// Invitations sent to an email address can't be represented until they're accepted
+		if invitation.GetLogin() == "" {
+			resources = append(resources, commentResource("github_membership", "NOTE there's a pending invitation for %s that can only be imported once accepted", invitation.GetEmail()))
+			continue
 		}
 
-		output, err := os.Create(fmt.Sprintf("%s/github_membership.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
+		// github_membership only has the member and admin roles
+		var role string
+		switch invitation.GetRole() {
+		case "direct_member":
+			role = "member"
+		case "admin":
+			role = "admin"
+		default:
+			resources = append(resources, commentResource("github_membership", "NOTE there's a pending invitation for %s with the %s role that can't be represented by github_membership, please review it", invitation.GetLogin(), invitation.GetRole()))
+			continue
 		}
-		defer output.Close()
 
-		for _, member := range members {
-			log.WithFields(logrus.Fields{
-				"Member": member.GetLogin(),
-			}).Debug("Processing membership")
+		resources = append(resources, s.membershipResource(invitation.GetLogin(), role, true))
+	}
 
-			membershipParse(member, output)
-		}
-	},
+	return resources, nil
 }
 
-func getOrgMembers() ([]*github.User, error) {
+func (s *session) getOrgMembers(role string) ([]*github.User, error) {
+	if s.archive != nil {
+		return s.archive.members(role), nil
+	}
+
+	if s.apiBackend == "graphql" {
+		return s.graphqlGetOrgMembers(role)
+	}
+
 	opt := &github.ListMembersOptions{
+		Role:        role,
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
 	var allMembers []*github.User
 	for {
-		members, resp, err := api.Organizations.ListMembers(ctx, orgName, opt)
+		members, resp, err := s.api.Organizations.ListMembers(s.ctx, s.orgName, opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -72,32 +113,77 @@ func getOrgMembers() ([]*github.User, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return allMembers, nil
 }
 
-func membershipParse(user *github.User, output *os.File) {
-	// Get the organization role for this member
-	membership, _, err := api.Organizations.GetOrgMembership(ctx, user.GetLogin(), orgName)
-	if err != nil {
-		log.Error(err)
-		return
+// getOrgMemberRoles returns the organization role of every member indexed by login
+func (s *session) getOrgMemberRoles() (map[string]string, error) {
+	roles := make(map[string]string)
+	for _, role := range []string{"admin", "member"} {
+		members, err := s.getOrgMembers(role)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range members {
+			roles[member.GetLogin()] = role
+		}
 	}
 
-	tmpl := template.Must(template.New("membership").Funcs(templateFuncMap).Parse(membershipTemplate))
-	err = tmpl.Execute(output,
-		struct {
-			Org      string
-			Username string
-			Role     string
-		}{
-			Org:      orgName,
-			Username: user.GetLogin(),
-			Role:     membership.GetRole(),
-		})
-	if err != nil {
-		log.Error(err)
+	return roles, nil
+}
+
+func (s *session) getOrgPendingInvitations() ([]*github.Invitation, error) {
+	if s.archive != nil {
+		return s.archive.PendingInvitations, nil
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+
+	var allInvitations []*github.Invitation
+	for {
+		invitations, resp, err := s.api.Organizations.ListPendingOrgInvitations(s.ctx, s.orgName, opt)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		allInvitations = append(allInvitations, invitations...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	return allInvitations, nil
+}
+
+// MembershipData is passed to the github_membership template
+type MembershipData struct {
+	// Org is the organization name
+	Org string
+	// Username is the login of the user
+	Username string
+	// Role is the role of the user
+	Role string
+	// Pending is true when the invitation wasn't accepted yet
+	Pending bool
+}
+
+func (s *session) membershipResource(username, role string, pending bool) *Resource {
+	return &Resource{
+		Type:     "github_membership",
+		Name:     s.normalizeResourceName(username),
+		ImportID: fmt.Sprintf("%s:%s", s.orgName, username),
+		Data: MembershipData{
+			Org:      s.orgName,
+			Username: username,
+			Role:     role,
+			Pending:  pending,
+		},
 	}
 }
@@ -1,13 +1,8 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-	"text/template"
-
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const organizationBlockTemplate = `
@@ -15,58 +10,57 @@ const organizationBlockTemplate = `
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
 # Suggestion: use this identifier instead _{{normalizeResourceName .Username}}
 {{- end}}
-# terraform import github_organization_block.{{normalizeResourceName .Username}} {{normalizeResourceName .Username}}
+# terraform import github_organization_block.{{normalizeResourceName .Username}} {{.Username}}
 resource "github_organization_block" "{{normalizeResourceName .Username}}" {
-  username = "{{.Username}}"
+  username = {{hclValue .Username}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(organizationBlockCmd)
-}
+var organizationBlockCmd = RegisterGenerator(&generator{
+	name:  "organization-block",
+	fetch: (*session).organizationBlockFetch,
+	templates: map[string]string{
+		"github_organization_block": organizationBlockTemplate,
+	},
+	outputFile: func(resource *Resource) string {
+		return "github_organization_blocks.tf"
+	},
+	organizationOnly: true,
+}, "Import organization blocked users into Terraform")
 
-var organizationBlockCmd = &cobra.Command{
-	Use:   "organization-block",
-	Short: "Import organization blocked users into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting organization blocked users data")
+func (s *session) organizationBlockFetch() ([]*Resource, error) {
+	s.log.Debug("Getting organization blocked users data")
 
-		users, err := getorganizationBlockedUsers()
-		if err != nil {
-			return
-		}
+	users, err := s.getorganizationBlockedUsers()
+	if err != nil {
+		return nil, err
+	}
 
-		if len(users) == 0 {
-			log.Info("Nothing found")
-			return
-		}
+	var resources []*Resource
+	for _, user := range users {
 
-		output, err := os.Create(fmt.Sprintf("%s/github_organization_blocks.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
-		}
-		defer output.Close()
-
-		for _, user := range users {
+		s.log.WithFields(logrus.Fields{
+			"User": user.GetLogin(),
+		}).Debug("Processing user block")
 
-			log.WithFields(logrus.Fields{
-				"User": user.GetLogin(),
-			}).Debug("Processing user block")
+		resources = append(resources, s.organizationBlockResource(user))
+	}
 
-			organizationBlockParse(user, output)
-		}
-	},
+	return resources, nil
 }
 
-func getorganizationBlockedUsers() ([]*github.User, error) {
+func (s *session) getorganizationBlockedUsers() ([]*github.User, error) {
+	if s.archive != nil {
+		return s.archive.BlockedUsers, nil
+	}
+
 	opt := &github.ListOptions{PerPage: 100}
 
 	var allUsers []*github.User
 	for {
-		users, resp, err := api.Organizations.ListBlockedUsers(ctx, orgName, opt)
+		users, resp, err := s.api.Organizations.ListBlockedUsers(s.ctx, s.orgName, opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -75,24 +69,28 @@ func getorganizationBlockedUsers() ([]*github.User, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return allUsers, nil
 }
 
-func organizationBlockParse(user *github.User, output *os.File) {
+// OrganizationBlockData is passed to the github_organization_block template
+type OrganizationBlockData struct {
+	// Org is the organization name
+	Org string
+	// Username is the login of the user
+	Username string
+}
 
-	tmpl := template.Must(template.New("organization-block").Funcs(templateFuncMap).Parse(organizationBlockTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org      string
-			Username string
-		}{
-			Org:      orgName,
+func (s *session) organizationBlockResource(user *github.User) *Resource {
+	return &Resource{
+		Type:     "github_organization_block",
+		Name:     s.normalizeResourceName(user.GetLogin()),
+		ImportID: user.GetLogin(),
+		Data: OrganizationBlockData{
+			Org:      s.orgName,
 			Username: user.GetLogin(),
-		})
-	if err != nil {
-		log.Error(err)
+		},
 	}
 }
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const providerTemplate = `
+provider "github" {
+  alias = {{hclValue .Alias}}
+  owner = {{hclValue .Org}}
+}
+`
+
+// organizations are the organizations given with --organization, the commands run once for each of them
+var organizations []string
+
+// organizationList returns the organizations from the flag, the environment or the configuration file,
+// either as a list or as comma separated values
+func organizationList() []string {
+	var orgs []string
+	for _, value := range viper.GetStringSlice("organization") {
+		for _, org := range strings.Split(value, ",") {
+			if org = strings.TrimSpace(org); org != "" && !contains(orgs, org) {
+				orgs = append(orgs, org)
+			}
+		}
+	}
+
+	return orgs
+}
+
+// forEachOrganization runs the command with a session for every organization. With several organizations,
+// each one is written to its own subdirectory of the output directory along with a provider aliased after
+// it, the API client and its cache are shared so they all draw from the same rate limit budget.
+func forEachOrganization(run func(s *session)) {
+	if len(organizations) <= 1 {
+		run(newSession(orgName, outDirectory))
+		return
+	}
+
+	for _, org := range organizations {
+		s := newSession(org, filepath.Join(outDirectory, org))
+		s.providerAlias = s.normalizeResourceName(org)
+
+		fields := logrus.Fields{
+			"Organization": org,
+			"Directory":    s.outDirectory,
+		}
+		if limits, _, err := s.api.RateLimits(s.ctx); err == nil {
+			fields["RateLimitRemaining"] = limits.GetCore().Remaining
+		}
+		s.log.WithFields(fields).Info("Processing organization")
+
+		if err := os.MkdirAll(s.outDirectory, 0755); err != nil {
+			s.log.Error(err)
+			return
+		}
+
+		run(s)
+	}
+}
+
+// writeProvider writes the aliased provider of the current organization along its resources, once per run
+func (s *session) writeProvider() error {
+	path := filepath.Join(s.outDirectory, "provider.tf")
+	if s.outputFormat == "json" {
+		path += ".json"
+	}
+	if s.providerAlias == "" || s.writtenFiles[path] {
+		return nil
+	}
+
+	if s.outputFormat == "json" {
+		data, err := (&terraformJSON{
+			Provider: map[string]interface{}{
+				"github": map[string]string{"alias": s.providerAlias, "owner": s.orgName},
+			},
+		}).marshal()
+		if err != nil {
+			return err
+		}
+		return s.writeOutputFile(path, data)
+	}
+
+	tmpl := template.Must(template.New("provider").Funcs(s.templateFuncs()).Parse(providerTemplate))
+
+	var output bytes.Buffer
+	err := executeTemplate(tmpl, &output, struct {
+		Alias string
+		Org   string
+	}{
+		Alias: s.providerAlias,
+		Org:   s.orgName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.writeOutputFile(path, output.Bytes())
+}
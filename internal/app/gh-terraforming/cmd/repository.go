@@ -1,13 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
-	"text/template"
+	"net/http"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const repositoryTemplate = `
@@ -15,88 +14,202 @@ const repositoryTemplate = `
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
 # Suggestion: use this identifier instead _{{normalizeResourceName .Repository.Name}}
 {{- end}}
-# terraform import github_repository.{{normalizeResourceName .Repository.Name}} {{normalizeResourceName .Repository.Name}}
+# terraform import github_repository.{{normalizeResourceName .Repository.Name}} {{.Repository.Name}}
 resource "github_repository" "{{normalizeResourceName .Repository.Name}}" {
-  name                   = "{{.Repository.Name}}"
-  {{if .Repository.Description}}description            = "{{.Repository.Description}}"
-  {{end -}}
-  {{if .Repository.Homepage}}homepage_url           = "{{.Repository.Homepage}}"
-  {{end -}}
-  {{ if not .Repository.Visibility }}private                = {{.Repository.Private}} {{else -}}visibility             = {{.Repository.Visibility}}
-  {{end}}
-  {{if .Repository.HasDownloads }}has_downloads          = {{.Repository.HasDownloads}}
-  {{end -}}
-  {{ if .Repository.HasIssues }}has_issues             = {{.Repository.HasIssues}}
-  {{end -}}
-  {{ if .Repository.HasProjects }}has_projects           = {{.Repository.HasProjects}}
-  {{end -}}
-  {{ if .Repository.HasWiki }}has_wiki               = {{.Repository.HasWiki}}
-  {{end -}}
-  {{ if .Repository.IsTemplate }}is_template            = {{.Repository.IsTemplate}}
-  {{end -}}
-  {{ if .Repository.AllowMergeCommit }}allow_merge_commit     = {{.Repository.AllowMergeCommit}}
-  {{end -}}
-  {{ if .Repository.AllowSquashMerge }}allow_squash_merge     = {{.Repository.AllowSquashMerge}}
-  {{end -}}
-  {{ if .Repository.AllowRebaseMerge }}allow_rebase_merge     = {{.Repository.AllowRebaseMerge}}
-  {{end -}}
-  {{ if .Repository.DeleteBranchOnMerge }}delete_branch_on_merge = {{.Repository.DeleteBranchOnMerge}}
-  {{end -}}
-  {{ if .Repository.AutoInit }}auto_init              = {{.Repository.AutoInit}}
-  {{end -}}
-  {{ if .Repository.LicenseTemplate }}license_template       = "{{.Repository.LicenseTemplate}}"
-  {{end -}}
-  {{ if .Repository.GitignoreTemplate }}gitignore_template     = "{{.Repository.GitignoreTemplate}}"
-  {{end -}}
-  {{ if not .Repository.Archived }}archived               = {{.Repository.Archived}}
-  {{end -}}
-  {{ if .Repository.Topics }}topics                 = [ {{range $i, $topic := .Repository.Topics}}{{if $i}}, {{end}}"{{$topic}}"{{end}} ]
-  {{end -}}
+  name = {{hclValue .Repository.Name}}
+  {{- attr "description" .Repository.Description ""}}
+  {{- attr "homepage_url" .Repository.Homepage ""}}
+  {{- if .Repository.Visibility}}
+  {{- attr "visibility" .Repository.Visibility nil}}
+  {{- else}}
+  {{- attr "private" .Repository.Private nil}}
+  {{- end}}
+  {{- attr "has_downloads" .Repository.HasDownloads nil}}
+  {{- attr "has_issues" .Repository.HasIssues nil}}
+  {{- attr "has_projects" .Repository.HasProjects nil}}
+  {{- attr "has_wiki" .Repository.HasWiki nil}}
+  {{- attr "is_template" .Repository.IsTemplate false}}
+  {{- attr "allow_merge_commit" .Repository.AllowMergeCommit true}}
+  {{- attr "allow_squash_merge" .Repository.AllowSquashMerge true}}
+  {{- attr "allow_rebase_merge" .Repository.AllowRebaseMerge true}}
+  {{- attr "allow_auto_merge" .Details.AllowAutoMerge false}}
+  {{- attr "allow_update_branch" .Details.AllowUpdateBranch false}}
+  {{- attr "squash_merge_commit_title" .Details.SquashMergeCommitTitle "COMMIT_OR_PR_TITLE"}}
+  {{- attr "squash_merge_commit_message" .Details.SquashMergeCommitMessage "COMMIT_MESSAGES"}}
+  {{- attr "merge_commit_title" .Details.MergeCommitTitle "MERGE_MESSAGE"}}
+  {{- attr "merge_commit_message" .Details.MergeCommitMessage "PR_TITLE"}}
+  {{- attr "delete_branch_on_merge" .Repository.DeleteBranchOnMerge false}}
+  {{- attr "web_commit_signoff_required" .Details.WebCommitSignoffRequired false}}
+  {{- attr "auto_init" .Repository.AutoInit false}}
+  {{- attr "license_template" .Repository.LicenseTemplate ""}}
+  {{- attr "gitignore_template" .Repository.GitignoreTemplate ""}}
+  {{- attr "archived" .Repository.Archived false}}
+  {{- attr "vulnerability_alerts" .Details.VulnerabilityAlerts nil}}
+  {{- attr "topics" .Repository.Topics nil}}
+  {{- with .Details.SecurityAndAnalysis}}
+
+  security_and_analysis {
+    {{- with .AdvancedSecurity}}
+    advanced_security {
+      status = {{hclValue .Status}}
+    }
+    {{- end}}
+    {{- with .SecretScanning}}
+    secret_scanning {
+      status = {{hclValue .Status}}
+    }
+    {{- end}}
+    {{- with .SecretScanningPushProtection}}
+    secret_scanning_push_protection {
+      status = {{hclValue .Status}}
+    }
+    {{- end}}
+  }
+  {{- end}}
+  {{- with .Repository.TemplateRepository}}
+
+  template {
+    owner      = {{hclValue .GetOwner.GetLogin}}
+    repository = {{hclValue .GetName}}
+  }
+  {{- end}}
+  {{- with .Details.Pages}}
+
+  pages {
+    {{- attr "build_type" .BuildType ""}}
+    {{- attr "cname" .CNAME ""}}
+    {{- if ne .BuildType "workflow"}}
+    {{- with .Source}}
+
+    source {
+      branch = {{hclValue .Branch}}
+      path   = {{hclValue .Path}}
+    }
+    {{- end}}
+    {{- end}}
+  }
+  {{- end}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(repositoryCmd)
+// RepositoryDetails holds the repository settings that go-github doesn't model yet, or that
+// are only available from the single repository and related endpoints
+type RepositoryDetails struct {
+	AllowAutoMerge           *bool                          `json:"allow_auto_merge"`
+	AllowUpdateBranch        *bool                          `json:"allow_update_branch"`
+	SquashMergeCommitTitle   *string                        `json:"squash_merge_commit_title"`
+	SquashMergeCommitMessage *string                        `json:"squash_merge_commit_message"`
+	MergeCommitTitle         *string                        `json:"merge_commit_title"`
+	MergeCommitMessage       *string                        `json:"merge_commit_message"`
+	WebCommitSignoffRequired *bool                          `json:"web_commit_signoff_required"`
+	SecurityAndAnalysis      *RepositorySecurityAndAnalysis `json:"security_and_analysis"`
+
+	// Fetched from separate endpoints, nil when the token isn't allowed to read them
+	VulnerabilityAlerts *bool            `json:"-"`
+	Pages               *RepositoryPages `json:"-"`
+}
+
+type RepositorySecurityAndAnalysis struct {
+	AdvancedSecurity             *RepositorySecurityStatus `json:"advanced_security"`
+	SecretScanning               *RepositorySecurityStatus `json:"secret_scanning"`
+	SecretScanningPushProtection *RepositorySecurityStatus `json:"secret_scanning_push_protection"`
+}
+
+type RepositorySecurityStatus struct {
+	Status string `json:"status"`
 }
 
-var repositoryCmd = &cobra.Command{
-	Use:   "repository",
-	Short: "Import repository resources into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting repository data")
+type RepositoryPages struct {
+	BuildType string `json:"build_type"`
+	CNAME     string `json:"cname"`
+	Source    *struct {
+		Branch string `json:"branch"`
+		Path   string `json:"path"`
+	} `json:"source"`
+}
+
+var repositoryCmd = RegisterGenerator(&generator{
+	name:  "repository",
+	fetch: (*session).repositoryFetch,
+	templates: map[string]string{
+		"github_repository": repositoryTemplate,
+	},
+}, "Import repository resources into Terraform")
+
+func (s *session) repositoryFetch() ([]*Resource, error) {
+	s.log.Debug("Getting repository data")
+
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, repo := range repos {
+		s.log.WithFields(logrus.Fields{
+			"Name": *repo.Name,
+		}).Debug("Processing repository")
 
-		repos, err := getRepositories()
+		// Listing repositories only returns a subset of their settings
+		fullRepo, details, err := s.getRepositoryDetails(repo)
 		if err != nil {
-			return
+			return nil, err
 		}
 
-		output, err := os.Create(fmt.Sprintf("%s/github_repository.tf", outDirectory))
+		resources = append(resources, s.repositoryResource(fullRepo, details))
+	}
+
+	return resources, nil
+}
+
+func (s *session) getRepositories() ([]*github.Repository, error) {
+	if s.archive != nil {
+		return s.archive.repositories(), nil
+	}
+
+	if s.apiBackend == "graphql" {
+		return s.graphqlGetRepositories()
+	}
+
+	if s.userMode {
+		return s.getUserRepositories()
+	}
+
+	opt := &github.RepositoryListByOrgOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var allRepos []*github.Repository
+	for {
+		repos, resp, err := s.api.Repositories.ListByOrg(s.ctx, s.orgName, opt)
 		if err != nil {
-			log.Error(err)
-			return
+			s.log.Error(err)
+			return nil, err
 		}
-		defer output.Close()
-
-		for _, repo := range repos {
-			log.WithFields(logrus.Fields{
-				"Name": *repo.Name,
-			}).Debug("Processing repository")
 
-			repositoryParse(repo, output)
+		allRepos = append(allRepos, repos...)
+		if resp.NextPage == 0 {
+			break
 		}
-	},
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	return allRepos, nil
 }
 
-func getRepositories() ([]*github.Repository, error) {
-	opt := &github.RepositoryListByOrgOptions{
+// getUserRepositories returns the repositories owned by the authenticated user
+func (s *session) getUserRepositories() ([]*github.Repository, error) {
+	opt := &github.RepositoryListOptions{
+		Affiliation: "owner",
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
 	var allRepos []*github.Repository
 	for {
-		repos, resp, err := api.Repositories.ListByOrg(ctx, orgName, opt)
+		repos, resp, err := s.api.Repositories.List(s.ctx, "", opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -105,23 +218,93 @@ func getRepositories() ([]*github.Repository, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return allRepos, nil
 }
 
-func repositoryParse(repo *github.Repository, output *os.File) {
-	tmpl := template.Must(template.New("repository").Funcs(templateFuncMap).Parse(repositoryTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org        string
-			Repository github.Repository
-		}{
-			Org:        orgName,
-			Repository: *repo,
-		})
+// getRepositoryDetails fetches the full repository and the settings that need extra calls
+func (s *session) getRepositoryDetails(repo *github.Repository) (*github.Repository, *RepositoryDetails, error) {
+	if s.archive != nil {
+		fullRepo, details, err := s.archive.repositoryDetails(repo)
+		if err != nil {
+			s.log.Error(err)
+		}
+		return fullRepo, details, err
+	}
+
+	var raw json.RawMessage
+	if _, err := s.apiGet(fmt.Sprintf("repos/%s/%s", s.orgName, repo.GetName()), &raw); err != nil {
+		s.log.Error(err)
+		return nil, nil, err
+	}
+
+	fullRepo := new(github.Repository)
+	if err := json.Unmarshal(raw, fullRepo); err != nil {
+		s.log.Error(err)
+		return nil, nil, err
+	}
+
+	details := new(RepositoryDetails)
+	if err := json.Unmarshal(raw, details); err != nil {
+		s.log.Error(err)
+		return nil, nil, err
+	}
+
+	// Reading the vulnerability alerts and Pages settings requires admin rights on the repository,
+	// that shouldn't stop the whole import
+	vulnerabilityAlerts, resp, err := s.api.Repositories.GetVulnerabilityAlerts(s.ctx, s.orgName, repo.GetName())
 	if err != nil {
-		log.Error(err)
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			s.log.Error(err)
+			return nil, nil, err
+		}
+		s.log.WithFields(logrus.Fields{
+			"Repository": repo.GetName(),
+		}).Warn("Vulnerability alerts are not available, leaving vulnerability_alerts out")
+	} else {
+		details.VulnerabilityAlerts = &vulnerabilityAlerts
+	}
+
+	if fullRepo.GetHasPages() {
+		pages := new(RepositoryPages)
+		resp, err := s.apiGet(fmt.Sprintf("repos/%s/%s/pages", s.orgName, repo.GetName()), pages)
+		if err != nil {
+			if resp == nil || resp.StatusCode != http.StatusForbidden {
+				s.log.Error(err)
+				return nil, nil, err
+			}
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+			}).Warn("Pages settings are not available, leaving pages out")
+		} else {
+			details.Pages = pages
+		}
+	}
+
+	return fullRepo, details, nil
+}
+
+// RepositoryData is passed to the github_repository template
+type RepositoryData struct {
+	// Org is the organization name
+	Org string
+	// Repository is the Github repository
+	Repository github.Repository
+	// Details holds the repository attributes missing from the repository list
+	Details RepositoryDetails
+}
+
+func (s *session) repositoryResource(repo *github.Repository, details *RepositoryDetails) *Resource {
+	return &Resource{
+		Type:     "github_repository",
+		Name:     s.normalizeResourceName(repo.GetName()),
+		ImportID: repo.GetName(),
+		Data: RepositoryData{
+			Org:        s.orgName,
+			Repository: *repo,
+			Details:    *details,
+		},
 	}
 }
@@ -2,84 +2,160 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"text/template"
+	"regexp"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const repositoryBranchTemplate = `
 {{- if hasLeadingDigit .Repo}}
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
-# Suggestion: use this identifier instead _{{normalizeResourceName .Repo}}-{{.Branch}}
+# Suggestion: use this identifier instead _{{normalizeResourceName .Repo}}-{{normalizeResourceName .Branch}}
+{{- end}}
+# terraform import github_branch.{{normalizeResourceName .Repo}}-{{normalizeResourceName .Branch}} {{.Repo}}:{{.Branch}}{{if .SourceBranch}}:{{.SourceBranch}}{{end}}
+resource "github_branch" "{{normalizeResourceName .Repo}}-{{normalizeResourceName .Branch}}" {
+{{- if .SourceBranch}}
+	repository    = {{hclValue .Repo}}
+	branch        = {{hclValue .Branch}}
+	source_branch = {{hclValue .SourceBranch}}
+	source_sha    = {{hclValue .SourceSHA}}
+
+	# the import doesn't read source_sha, which forces a new branch when it changes
+	lifecycle {
+		ignore_changes = [source_sha]
+	}
+{{- else}}
+	repository = {{hclValue .Repo}}
+	branch     = {{hclValue .Branch}}
 {{- end}}
-# terraform import github_repository_branch.{{normalizeResourceName .Repo}}-{{.Branch}} {{normalizeResourceName .Repo}}:{{.Branch}}
-resource "github_repository_branch" "{{normalizeResourceName .Repo}}-{{.Branch}}" {
-	repository = "{{.Repo}}"
-	branch     = "{{.Branch}}"
 }
 `
 
+const repositoryBranchDefaultTemplate = `
+{{- if hasLeadingDigit .Repo}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .Repo}}
+{{- end}}
+# terraform import github_branch_default.{{normalizeResourceName .Repo}} {{.Repo}}
+resource "github_branch_default" "{{normalizeResourceName .Repo}}" {
+	repository = {{hclValue .Repo}}
+	branch     = {{hclValue .Branch}}
+}
+`
+
+var branchDefaultOnly, branchProtectedOnly bool
+var branchPattern string
+
+var repositoryBranchCmd = RegisterGenerator(&generator{
+	name:  "repository-branch",
+	fetch: (*session).repositoryBranchFetch,
+	templates: map[string]string{
+		"github_branch":         repositoryBranchTemplate,
+		"github_branch_default": repositoryBranchDefaultTemplate,
+	},
+	outputFile: func(resource *Resource) string {
+		if resource.Type == "github_branch" {
+			return "github_repository_branch.tf"
+		}
+		return ""
+	},
+}, "Import repository branches into Terraform")
+
 func init() {
-	rootCmd.AddCommand(repositoryBranchCmd)
+	repositoryBranchCmd.Flags().BoolVar(&branchDefaultOnly, "default-only", false, "Only export the default branch of each repository")
+	repositoryBranchCmd.Flags().BoolVar(&branchProtectedOnly, "protected-only", false, "Only export protected branches")
+	repositoryBranchCmd.Flags().StringVar(&branchPattern, "pattern", "", "Only export branches whose name matches this regular expression")
 }
 
-var repositoryBranchCmd = &cobra.Command{
-	Use:   "repository-branch",
-	Short: "Import repository branches into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting repository branches data")
+func (s *session) repositoryBranchFetch() ([]*Resource, error) {
+	s.log.Debug("Getting repository branches data")
 
-		// first get repositories, then for each repo, get its branches
-		repos, err := getRepositories()
-		if err != nil {
-			return
+	var pattern *regexp.Regexp
+	if s.branchPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(s.branchPattern); err != nil {
+			s.log.Error(err)
+			return nil, err
 		}
+	}
 
-		output, err := os.Create(fmt.Sprintf("%s/github_repository_branch.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
+	// first get repositories, then for each repo, get its branches
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, repo := range repos {
+
+		// empty repositories don't have a default branch yet
+		if repo.GetDefaultBranch() == "" {
+			continue
 		}
-		defer output.Close()
 
-		//TODO: remove this warning once we support getting source branch and sha
-		fmt.Fprintln(output, "# WARNING: for now the tool will not output source_branch and source_sha therefore assuming their default values")
-		fmt.Fprintln(output, "# as per provider documentation: https://registry.terraform.io/providers/hashicorp/github/latest/docs/resources/branch")
-		fmt.Fprintln(output, "# Support for getting the actual values will be added eventually")
+		branches, err := s.getRepositoryBranches(repo)
+		if err != nil {
+			return nil, err
+		}
 
-		for _, repo := range repos {
+		// the default branch resource is only exported when the default branch passes the filters too
+		var branchResources []*Resource
+		defaultKept := false
+		for _, branch := range branches {
 
-			branches, err := getRepositoryBranches(repo)
-			if err != nil {
-				return
+			isDefault := branch.GetName() == repo.GetDefaultBranch()
+			if s.branchDefaultOnly && !isDefault {
+				continue
+			}
+			if pattern != nil && !pattern.MatchString(branch.GetName()) {
+				continue
+			}
+			if isDefault {
+				defaultKept = true
 			}
 
-			for _, branch := range branches {
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+				"Branch":     branch.GetName(),
+			}).Debug("Processing repository")
 
-				log.WithFields(logrus.Fields{
-					"Repository": repo.GetName(),
-					"Branch":     branch.GetName(),
-				}).Debug("Processing repository")
+			branchResources = append(branchResources, s.repositoryBranchResource(repo, branch))
+		}
 
-				repositoryBranchParse(repo, branch, output)
-			}
+		if defaultKept {
+			resources = append(resources, s.repositoryBranchDefaultResource(repo))
 		}
-	},
+		resources = append(resources, branchResources...)
+	}
+
+	return resources, nil
 }
 
-func getRepositoryBranches(repo *github.Repository) ([]*github.Branch, error) {
+func (s *session) getRepositoryBranches(repo *github.Repository) ([]*github.Branch, error) {
+	if s.archive != nil {
+		return s.archive.repositoryBranches(repo, s.branchProtectedOnly), nil
+	}
+
+	if s.apiBackend == "graphql" {
+		if result, ok, err := s.graphqlGetRepositoryBranches(repo); err != nil || ok {
+			return result, err
+		}
+	}
+
 	opt := &github.BranchListOptions{
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
+	if s.branchProtectedOnly {
+		opt.Protected = &s.branchProtectedOnly
+	}
+
 	var allBranches []*github.Branch
 	for {
-		branches, resp, err := api.Repositories.ListBranches(ctx, orgName, repo.GetName(), opt)
+		branches, resp, err := s.api.Repositories.ListBranches(s.ctx, s.orgName, repo.GetName(), opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -88,26 +164,101 @@ func getRepositoryBranches(repo *github.Repository) ([]*github.Branch, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return allBranches, nil
 }
 
-func repositoryBranchParse(repo *github.Repository, branch *github.Branch, output *os.File) {
+// RepositoryBranchData is passed to the github_branch template
+type RepositoryBranchData struct {
+	// Org is the organization name
+	Org string
+	// Repo is the repository name
+	Repo string
+	// Branch is the branch name
+	Branch string
+	// IsDefault is true for the default branch of the repository
+	IsDefault bool
+	// SourceBranch is the branch the branch was created from, empty when unknown
+	SourceBranch string
+	// SourceSHA is the commit the branch was created from, empty when unknown
+	SourceSHA string
+}
 
-	tmpl := template.Must(template.New("repository-branch").Funcs(templateFuncMap).Parse(repositoryBranchTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org    string
-			Repo   string
-			Branch string
-		}{
-			Org:    orgName,
-			Repo:   repo.GetName(),
-			Branch: branch.GetName(),
-		})
+// getRepositoryBranchSource figures out where a branch was created from, using the point where it diverged
+// from the repository default branch. Empty values are returned when there's no common history between them.
+func (s *session) getRepositoryBranchSource(repo *github.Repository, branch *github.Branch) (string, string) {
+	if s.archive != nil {
+		return s.archive.repositoryBranchSource(repo, branch)
+	}
+
+	comparison, _, err := s.api.Repositories.CompareCommits(s.ctx, s.orgName, repo.GetName(), repo.GetDefaultBranch(), branch.GetName())
 	if err != nil {
-		log.Error(err)
+		s.log.WithFields(logrus.Fields{
+			"Repository": repo.GetName(),
+			"Branch":     branch.GetName(),
+		}).Warn(err)
+		return "", ""
+	}
+
+	sha := comparison.GetMergeBaseCommit().GetSHA()
+	if sha == "" {
+		return "", ""
+	}
+
+	return repo.GetDefaultBranch(), sha
+}
+
+// repositoryBranchResource sets source_branch through the import ID. The import doesn't read source_sha,
+// so the template tells Terraform to ignore its changes instead of replacing the imported branch.
+func (s *session) repositoryBranchResource(repo *github.Repository, branch *github.Branch) *Resource {
+	isDefault := branch.GetName() == repo.GetDefaultBranch()
+
+	var sourceBranch, sourceSHA string
+	if !isDefault {
+		sourceBranch, sourceSHA = s.getRepositoryBranchSource(repo, branch)
+	}
+
+	importID := fmt.Sprintf("%s:%s", repo.GetName(), branch.GetName())
+	if sourceBranch != "" {
+		importID = fmt.Sprintf("%s:%s", importID, sourceBranch)
+	}
+
+	return &Resource{
+		Type:     "github_branch",
+		Name:     fmt.Sprintf("%s-%s", s.normalizeResourceName(repo.GetName()), s.normalizeResourceName(branch.GetName())),
+		ImportID: importID,
+		Data: RepositoryBranchData{
+			Org:          s.orgName,
+			Repo:         repo.GetName(),
+			Branch:       branch.GetName(),
+			IsDefault:    isDefault,
+			SourceBranch: sourceBranch,
+			SourceSHA:    sourceSHA,
+		},
+	}
+}
+
+// RepositoryBranchDefaultData is passed to the github_branch_default template
+type RepositoryBranchDefaultData struct {
+	// Org is the organization name
+	Org string
+	// Repo is the repository name
+	Repo string
+	// Branch is the branch name
+	Branch string
+}
+
+func (s *session) repositoryBranchDefaultResource(repo *github.Repository) *Resource {
+	return &Resource{
+		Type:     "github_branch_default",
+		Name:     s.normalizeResourceName(repo.GetName()),
+		ImportID: repo.GetName(),
+		Data: RepositoryBranchDefaultData{
+			Org:    s.orgName,
+			Repo:   repo.GetName(),
+			Branch: repo.GetDefaultBranch(),
+		},
 	}
 }
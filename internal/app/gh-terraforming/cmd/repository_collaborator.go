@@ -1,134 +1,491 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"text/template"
+	"net/http"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const repositoryCollaboratorTemplate = `
 {{- if hasLeadingDigit .RepoName}}
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
-# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}-{{.UserName}}
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}-{{normalizeResourceName .UserName}}
 {{- end}}
-# terraform import github_repository_collaborator.{{normalizeResourceName .RepoName}}-{{.UserName}} {{.RepoName}}:{{.UserName}}
-resource "github_repository_collaborator" "{{normalizeResourceName .RepoName}}-{{.UserName}}" {
-  repository = "{{.RepoName}}"
-  username   = "{{.UserName}}"
-  permission = "{{.Permission}}" 
+{{- if .Pending}}
+# NOTE this is a pending invitation that hasn't been accepted yet, please review it
+{{- end}}
+# terraform import github_repository_collaborator.{{normalizeResourceName .RepoName}}-{{normalizeResourceName .UserName}} {{.RepoName}}:{{.UserName}}
+resource "github_repository_collaborator" "{{normalizeResourceName .RepoName}}-{{normalizeResourceName .UserName}}" {
+  repository = {{hclValue .RepoName}}
+  username   = {{hclValue .UserName}}
+  {{- attr "permission" .Permission "push"}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(repositoryCollaboratorCmd)
+const repositoryCollaboratorsTemplate = `
+{{- if hasLeadingDigit .RepoName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}
+{{- end}}
+{{- range .Unresolved}}
+# WARNING {{.}} is a direct collaborator that also gets access from a team or the organization, the permission
+# of the direct collaboration can't be told apart and is left out, applying this resource removes it, please review it
+{{- end}}
+# terraform import github_repository_collaborators.{{normalizeResourceName .RepoName}} {{.RepoName}}
+resource "github_repository_collaborators" "{{normalizeResourceName .RepoName}}" {
+  repository = {{hclValue .RepoName}}
+  {{- range .Users}}
+
+  user {
+    {{- if .Pending}}
+    # NOTE this is a pending invitation that hasn't been accepted yet, please review it
+    {{- end}}
+    username = {{hclValue .Name}}
+    {{- attr "permission" .Permission "push"}}
+  }
+  {{- end}}
+  {{- range .Teams}}
+
+  team {
+    team_id = {{hclValue .Name}}
+    {{- attr "permission" .Permission "push"}}
+  }
+  {{- end}}
 }
+`
 
-var repositoryCollaboratorCmd = &cobra.Command{
-	Use:   "repository-collaborator",
-	Short: "Import organization repository collaborators into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting repository collaborator data")
+// errCollaboratorsUnreadable is returned when the token isn't allowed to read the collaborators of a repository
+var errCollaboratorsUnreadable = errors.New("the repository collaborators can't be read")
 
-		// first get repositories, then for each repo, get its collaborators
-		repos, err := getRepositories()
-		if err != nil {
-			return
+// RepositoryGrant is the permission a user or a team has on a repository
+type RepositoryGrant struct {
+	Name       string
+	Permission string
+	Pending    bool
+}
+
+var repositoryCollaboratorCmd = RegisterGenerator(&generator{
+	name:  "repository-collaborator",
+	fetch: (*session).repositoryCollaboratorFetch,
+	templates: map[string]string{
+		"github_repository_collaborator":  repositoryCollaboratorTemplate,
+		"github_repository_collaborators": repositoryCollaboratorsTemplate,
+	},
+	outputFile: func(resource *Resource) string {
+		// external collaborators and organization members are generated to two separate files
+		if data, ok := resource.Data.(RepositoryCollaboratorData); ok && data.Affiliation == "outside" {
+			return "github_repository_external_collaborator.tf"
 		}
+		return ""
+	},
+}, "Import organization repository collaborators into Terraform")
+
+func (s *session) repositoryCollaboratorFetch() ([]*Resource, error) {
+	s.log.Debug("Getting repository collaborator data")
+
+	// first get repositories, then for each repo, get its collaborators
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.getRepositoryAccess()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.authoritative {
+		return s.repositoryCollaboratorsFetch(repos, access)
+	}
 
-		// generate the code to two separate files, one for external collaborators and another for org members
-		output := make(map[string]*os.File)
+	var resources []*Resource
+	var externalCollaborators []*github.User
+	for _, repo := range repos {
 
-		// file for external collaborators
-		output["outside"], err = os.Create(fmt.Sprintf("%s/github_repository_external_collaborator.tf", outDirectory))
+		repoTeams, err := s.getRepositoryTeams(repo)
 		if err != nil {
-			log.Error(err)
-			return
+			return nil, err
 		}
-		defer output["outside"].Close()
 
-		// file for organization members
-		output["direct"], err = os.Create(fmt.Sprintf("%s/github_repository_collaborator.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
+		// personal repositories don't have outside collaborators, only direct ones
+		affiliations := []string{"outside", "direct"}
+		if s.userMode {
+			affiliations = []string{"direct"}
 		}
-		defer output["direct"].Close()
 
-		var externalCollaborators []*github.User
-		for _, repo := range repos {
+		for _, affiliation := range affiliations {
+
+			collaborators, err := s.getOrgRepositoryCollaborators(repo, affiliation)
+			if err == errCollaboratorsUnreadable {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			/*
+				github's api will return all the repositories collaborators when we query
+				with direct affiliation but we want to exclude the outside ones from that list,
+				so let's save the outside collaborators in a separate variable
+			*/
+			if affiliation == "outside" {
+				externalCollaborators = collaborators
+
+			} else {
+
+				// Now we iterate the list of outside collaborators and exclude them from the direct list
+				for _, external := range externalCollaborators {
+
+					var updatedCollaborators []*github.User
+					for _, collaborator := range collaborators {
+
+						if external.GetLogin() != collaborator.GetLogin() {
+							updatedCollaborators = append(updatedCollaborators, collaborator)
+						}
+					}
+
+					collaborators = updatedCollaborators
+				}
+
+			}
+
+			for _, collaborator := range collaborators {
 
-			for _, affiliation := range []string{"outside", "direct"} {
+				s.log.WithFields(logrus.Fields{
+					"Repository":   repo.GetName(),
+					"Collaborator": collaborator.GetLogin(),
+					"Affiliation":  affiliation,
+				}).Debug("Processing repository collaborator")
 
-				collaborators, err := getOrgRepositoryCollaborators(repo, affiliation)
+				// Figure out the collaborator permission for this repository
+				permission := highestPermission(collaborator.GetPermissions())
+				if permission == "" {
+					continue
+				}
+
+				explained, err := access.explains(s, repo, repoTeams, collaborator, permission)
 				if err != nil {
-					return
+					return nil, err
+				}
+				if explained {
+					continue
 				}
 
-				/*
-					github's api will return all the repositories collaborators when we query
-					with direct affiliation but we want to exclude the outside ones from that list,
-					so let's save the outside collaborators in a separate variable
-				*/
-				if affiliation == "outside" {
-					externalCollaborators = collaborators
+				resources = append(resources, s.repositoryCollaboratorResource(repo, collaborator.GetLogin(), permission, affiliation, false))
+			}
+		}
 
-				} else {
+		invitations, err := s.getRepositoryInvitations(repo)
+		if err != nil {
+			return nil, err
+		}
 
-					// Now we iterate the list of outside collaborators and exclude them from the direct list
-					for _, external := range externalCollaborators {
+		for _, invitation := range invitations {
+			invitee := invitation.GetInvitee().GetLogin()
 
-						var updatedCollaborators []*github.User
-						for _, collaborator := range collaborators {
+			affiliation := "outside"
+			if s.userMode || access.members[invitee] {
+				affiliation = "direct"
+			}
 
-							if external.GetLogin() != collaborator.GetLogin() {
-								updatedCollaborators = append(updatedCollaborators, collaborator)
-							}
-						}
+			s.log.WithFields(logrus.Fields{
+				"Repository":   repo.GetName(),
+				"Collaborator": invitee,
+				"Affiliation":  affiliation,
+			}).Debug("Processing repository invitation")
 
-						collaborators = updatedCollaborators
-					}
+			resources = append(resources, s.repositoryCollaboratorResource(repo, invitee, apiPermission(invitation.GetPermissions()), affiliation, true))
+		}
+	}
+
+	return resources, nil
+}
+
+// repositoryCollaboratorsFetch generates a single authoritative github_repository_collaborators
+// resource per repository, covering both collaborators and teams
+func (s *session) repositoryCollaboratorsFetch(repos []*github.Repository, access *repositoryAccess) ([]*Resource, error) {
+	var resources []*Resource
+	for _, repo := range repos {
+
+		// the direct affiliation includes outside collaborators
+		collaborators, err := s.getOrgRepositoryCollaborators(repo, "direct")
+		if err == errCollaboratorsUnreadable {
+			// the resource is authoritative, without the collaborators applying it would remove all of them
+			resources = append(resources, commentResource("github_repository_collaborators", "WARNING the collaborators of %s can't be read, skipping its github_repository_collaborators resource", repo.GetName()))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		repoTeams, err := s.getRepositoryTeams(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		// Github reports the effective permission of collaborators, when something else grants it too the
+		// permission of the collaboration itself is unknown. Granting the effective permission would raise
+		// it, so these collaborators are left out with a warning instead.
+		var users []RepositoryGrant
+		var unresolved []string
+		for _, collaborator := range collaborators {
+			permission := highestPermission(collaborator.GetPermissions())
+			if permission == "" {
+				continue
+			}
 
+			explained, err := access.explains(s, repo, repoTeams, collaborator, permission)
+			if err != nil {
+				return nil, err
+			}
+			if explained {
+				s.log.WithFields(logrus.Fields{
+					"Repository":   repo.GetName(),
+					"Collaborator": collaborator.GetLogin(),
+				}).Warn("Can't tell the direct permission of a collaborator that also has indirect access, leaving it out")
+				unresolved = append(unresolved, collaborator.GetLogin())
+				continue
+			}
+
+			users = append(users, RepositoryGrant{Name: collaborator.GetLogin(), Permission: permission})
+		}
+
+		invitations, err := s.getRepositoryInvitations(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, invitation := range invitations {
+			users = append(users, RepositoryGrant{
+				Name:       invitation.GetInvitee().GetLogin(),
+				Permission: apiPermission(invitation.GetPermissions()),
+				Pending:    true,
+			})
+		}
+
+		var teams []RepositoryGrant
+		for _, team := range repoTeams {
+			teams = append(teams, RepositoryGrant{Name: team.GetSlug(), Permission: team.GetPermission()})
+		}
+
+		if len(users) == 0 && len(teams) == 0 && len(unresolved) == 0 {
+			continue
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"Repository": repo.GetName(),
+		}).Debug("Processing repository collaborators")
+
+		resources = append(resources, s.repositoryCollaboratorsResource(repo, users, teams, unresolved))
+	}
+
+	return resources, nil
+}
+
+// repositoryAccess holds what grants users access to repositories besides being a collaborator:
+// the organization base permission, being an organization owner and being a member of a team
+type repositoryAccess struct {
+	basePermission string
+	owners         map[string]bool
+	members        map[string]bool
+	teamMembers    map[int64]map[string]bool
+}
+
+func (s *session) getOrganization() (*github.Organization, error) {
+	if s.archive != nil {
+		return s.archive.Org, nil
+	}
+
+	org, _, err := s.api.Organizations.Get(s.ctx, s.orgName)
+	if err != nil {
+		s.log.Error(err)
+		return nil, err
+	}
+
+	return org, nil
+}
+
+func (s *session) getRepositoryAccess() (*repositoryAccess, error) {
+	if s.userMode {
+		return &repositoryAccess{teamMembers: make(map[int64]map[string]bool)}, nil
+	}
+
+	org, err := s.getOrganization()
+	if err != nil {
+		return nil, err
+	}
+
+	access := &repositoryAccess{
+		owners:      make(map[string]bool),
+		members:     make(map[string]bool),
+		teamMembers: make(map[int64]map[string]bool),
+	}
+
+	access.basePermission = apiPermission(org.GetDefaultRepoPermission())
+
+	roles, err := s.getOrgMemberRoles()
+	if err != nil {
+		return nil, err
+	}
+	for login, role := range roles {
+		access.members[login] = true
+		access.owners[login] = role == "admin"
+	}
+
+	return access, nil
+}
+
+// explains reports whether the user permission on the repository is already granted by something
+// other than a direct collaboration, like a team or the organization base permission. Team members
+// are fetched the first time a team is needed.
+func (a *repositoryAccess) explains(s *session, repo *github.Repository, repoTeams []*github.Team, user *github.User, permission string) (bool, error) {
+	login := user.GetLogin()
+
+	source := ""
+	switch {
+	case s.userMode && login == s.orgName:
+		source = "repository owner"
+	case a.owners[login]:
+		source = "organization owner"
+	case a.members[login] && permissionRank(a.basePermission) >= permissionRank(permission):
+		source = "organization base permission"
+	default:
+		for _, team := range repoTeams {
+			if permissionRank(team.GetPermission()) < permissionRank(permission) {
+				continue
+			}
+
+			if _, ok := a.teamMembers[team.GetID()]; !ok {
+				teamMembers, err := s.getOrgTeamMemberships(team, "all")
+				if err != nil {
+					return false, err
 				}
 
-				for _, collaborator := range collaborators {
-
-					log.WithFields(logrus.Fields{
-						"Repository":   repo.GetName(),
-						"Collaborator": collaborator.GetLogin(),
-						"Affiliation":  affiliation,
-					}).Debug("Processing repository collaborator")
-
-					// Figure out the collaborator permission for this repository
-					// Order is admin, maintain, push(write), triage, pull(read)
-					permissions := collaborator.GetPermissions()
-					for _, permission := range []string{"admin", "maintain", "push", "triage", "pull"} {
-						if permissions[permission] {
-							repositoryCollaboratorParse(repo, collaborator, permission, output[affiliation])
-							break
-						}
-					}
+				a.teamMembers[team.GetID()] = make(map[string]bool)
+				for _, member := range teamMembers {
+					a.teamMembers[team.GetID()][member.GetLogin()] = true
 				}
 			}
+
+			if a.teamMembers[team.GetID()][login] {
+				source = fmt.Sprintf("team %s", team.GetName())
+				break
+			}
 		}
-	},
+	}
+
+	if source == "" {
+		return false, nil
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"Repository":   repo.GetName(),
+		"Collaborator": login,
+		"Permission":   permission,
+		"Source":       source,
+	}).Debug("Skipping repository collaborator with indirect access")
+
+	return true, nil
+}
+
+func (s *session) getRepositoryInvitations(repo *github.Repository) ([]*github.RepositoryInvitation, error) {
+	if s.archive != nil {
+		return s.archive.repository(repo).Invitations, nil
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+
+	var repoInvitations []*github.RepositoryInvitation
+	for {
+		invitations, resp, err := s.api.Repositories.ListInvitations(s.ctx, s.orgName, repo.GetName(), opt)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		repoInvitations = append(repoInvitations, invitations...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	return repoInvitations, nil
 }
 
-func getOrgRepositoryCollaborators(repo *github.Repository, affiliation string) ([]*github.User, error) {
+func (s *session) getRepositoryTeams(repo *github.Repository) ([]*github.Team, error) {
+	if s.archive != nil {
+		return s.archive.repository(repo).Teams, nil
+	}
+
+	// only organization repositories can be shared with teams
+	if s.userMode {
+		return nil, nil
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+
+	var repoTeams []*github.Team
+	for {
+		teams, resp, err := s.api.Repositories.ListTeams(s.ctx, s.orgName, repo.GetName(), opt)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		repoTeams = append(repoTeams, teams...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	return repoTeams, nil
+}
+
+// getOrgRepositoryCollaborators returns errCollaboratorsUnreadable when the token can't read the collaborators,
+// the list is never nil otherwise
+func (s *session) getOrgRepositoryCollaborators(repo *github.Repository, affiliation string) ([]*github.User, error) {
+	if s.archive != nil {
+		collaborators := s.archive.repository(repo).Collaborators[affiliation]
+		if collaborators == nil {
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+			}).Warn("The repository collaborators weren't read in the snapshot, skipping them")
+			return nil, errCollaboratorsUnreadable
+		}
+		return collaborators, nil
+	}
+
+	if s.apiBackend == "graphql" {
+		if result, ok, err := s.graphqlGetRepositoryCollaborators(repo, affiliation); err != nil || ok {
+			return result, err
+		}
+	}
+
 	opt := &github.ListCollaboratorsOptions{
 		Affiliation: affiliation,
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
-	var repoCollaborators []*github.User
+	repoCollaborators := make([]*github.User, 0)
 	for {
-		repos, resp, err := api.Repositories.ListCollaborators(ctx, orgName, repo.GetName(), opt)
+		repos, resp, err := s.api.Repositories.ListCollaborators(s.ctx, s.orgName, repo.GetName(), opt)
 		if err != nil {
-			log.Error(err)
-			return nil, err
+			// reading the collaborators requires push access to the repository
+			if resp == nil || resp.StatusCode != http.StatusForbidden {
+				s.log.Error(err)
+				return nil, err
+			}
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+			}).Warn("Can't read the repository collaborators, skipping them")
+			return nil, errCollaboratorsUnreadable
 		}
 
 		repoCollaborators = append(repoCollaborators, repos...)
@@ -136,27 +493,69 @@ func getOrgRepositoryCollaborators(repo *github.Repository, affiliation string)
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return repoCollaborators, nil
 }
 
-func repositoryCollaboratorParse(repo *github.Repository, collaborator *github.User, permission string, output *os.File) {
-	tmpl := template.Must(template.New("repository-collaborator").Funcs(templateFuncMap).Parse(repositoryCollaboratorTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org        string
-			RepoName   string
-			UserName   string
-			Permission string
-		}{
-			Org:        orgName,
+// RepositoryCollaboratorData is passed to the github_repository_collaborator template
+type RepositoryCollaboratorData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// UserName is the login of the user
+	UserName string
+	// Permission is the permission granted on the repository
+	Permission string
+	// Affiliation is outside for outside collaborators, direct for organization members
+	Affiliation string
+	// Pending is true when the invitation wasn't accepted yet
+	Pending bool
+}
+
+func (s *session) repositoryCollaboratorResource(repo *github.Repository, username, permission, affiliation string, pending bool) *Resource {
+	return &Resource{
+		Type:     "github_repository_collaborator",
+		Name:     fmt.Sprintf("%s-%s", s.normalizeResourceName(repo.GetName()), s.normalizeResourceName(username)),
+		ImportID: fmt.Sprintf("%s:%s", repo.GetName(), username),
+		Data: RepositoryCollaboratorData{
+			Org:         s.orgName,
+			RepoName:    repo.GetName(),
+			UserName:    username,
+			Permission:  permission,
+			Affiliation: affiliation,
+			Pending:     pending,
+		},
+	}
+}
+
+// RepositoryCollaboratorsData is passed to the github_repository_collaborators template
+type RepositoryCollaboratorsData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// Users are the users with access to the repository
+	Users []RepositoryGrant
+	// Teams are the teams with access to the repository
+	Teams []RepositoryGrant
+	// Unresolved are the direct collaborators left out because their direct permission is unknown
+	Unresolved []string
+}
+
+func (s *session) repositoryCollaboratorsResource(repo *github.Repository, users, teams []RepositoryGrant, unresolved []string) *Resource {
+	return &Resource{
+		Type:     "github_repository_collaborators",
+		Name:     s.normalizeResourceName(repo.GetName()),
+		ImportID: repo.GetName(),
+		Data: RepositoryCollaboratorsData{
+			Org:        s.orgName,
 			RepoName:   repo.GetName(),
-			UserName:   collaborator.GetLogin(),
-			Permission: permission,
-		})
-	if err != nil {
-		log.Error(err)
+			Users:      users,
+			Teams:      teams,
+			Unresolved: unresolved,
+		},
 	}
 }
@@ -2,12 +2,9 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"text/template"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const repositoryWebhookTemplate = `
@@ -15,72 +12,73 @@ const repositoryWebhookTemplate = `
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
 # Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}-{{.ID}}
 {{- end}}
-# terraform import github_repository_webhook.{{normalizeResourceName .RepoName}}-{{.ID}} {{normalizeResourceName .RepoName}}/{{.ID}}
+# terraform import github_repository_webhook.{{normalizeResourceName .RepoName}}-{{.ID}} {{.RepoName}}/{{.ID}}
 resource "github_repository_webhook" "{{normalizeResourceName .RepoName}}-{{.ID}}" {
-	repository = "{{.RepoName}}"
-	active     = {{.Active}}
-	events     = [ {{range $i, $event := .Events}}{{if $i}}, {{end}}"{{$event}}"{{end}} ]
+	repository = {{hclValue .RepoName}}
+	{{- attr "active" .Active true}}
+	events     = {{hclValue .Events}}
 
 	configuration {
-		url          = "{{.Url}}"
-		content_type = "{{.ContentType}}"
-		insecure_ssl = {{.InsecureSSL}}
-		{{if .Secret}}secret       = "PLEASE UPDATE ME"
-		{{end -}}
+		url = {{hclValue .URL}}
+		{{- attr "content_type" .ContentType ""}}
+		{{- attr "insecure_ssl" .InsecureSSL false}}
+		{{- if .Secret}}
+		secret = "PLEASE UPDATE ME"
+		{{- end}}
 	}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(repositoryWebhookCmd)
-}
+var repositoryWebhookCmd = RegisterGenerator(&generator{
+	name:  "repository-webhook",
+	fetch: (*session).repositoryWebhookFetch,
+	templates: map[string]string{
+		"github_repository_webhook": repositoryWebhookTemplate,
+	},
+}, "Import repository webhooks into Terraform")
 
-var repositoryWebhookCmd = &cobra.Command{
-	Use:   "repository-webhook",
-	Short: "Import repository webhooks into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting repository webhooks data")
+func (s *session) repositoryWebhookFetch() ([]*Resource, error) {
+	s.log.Debug("Getting repository webhooks data")
 
-		// first get repositories, then for each repo, get its webhooks
-		repos, err := getRepositories()
-		if err != nil {
-			return
-		}
+	// first get repositories, then for each repo, get its webhooks
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, repo := range repos {
 
-		output, err := os.Create(fmt.Sprintf("%s/github_repository_webhook.tf", outDirectory))
+		webhooks, err := s.getRepositoryWebhooks(repo)
 		if err != nil {
-			log.Error(err)
-			return
+			return nil, err
 		}
-		defer output.Close()
-
-		for _, repo := range repos {
-
-			webhooks, err := getRepositoryWebhooks(repo)
-			if err != nil {
-				return
-			}
 
-			for _, webhook := range webhooks {
+		for _, webhook := range webhooks {
 
-				log.WithFields(logrus.Fields{
-					"Name": *repo.Name,
-				}).Debug("Processing repository")
+			s.log.WithFields(logrus.Fields{
+				"Name": *repo.Name,
+			}).Debug("Processing repository")
 
-				repositoryWebhookParse(repo, webhook, output)
-			}
+			resources = append(resources, s.repositoryWebhookResource(repo, webhook))
 		}
-	},
+	}
+
+	return resources, nil
 }
 
-func getRepositoryWebhooks(repo *github.Repository) ([]*github.Hook, error) {
+func (s *session) getRepositoryWebhooks(repo *github.Repository) ([]*github.Hook, error) {
+	if s.archive != nil {
+		return s.archive.repository(repo).Webhooks, nil
+	}
+
 	opt := &github.ListOptions{PerPage: 100}
 
 	var allWebhooks []*github.Hook
 	for {
-		webhooks, resp, err := api.Repositories.ListHooks(ctx, orgName, repo.GetName(), opt)
+		webhooks, resp, err := s.api.Repositories.ListHooks(s.ctx, s.orgName, repo.GetName(), opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -89,55 +87,56 @@ func getRepositoryWebhooks(repo *github.Repository) ([]*github.Hook, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return allWebhooks, nil
 }
 
-func repositoryWebhookParse(repo *github.Repository, webhook *github.Hook, output *os.File) {
-	config := webhook.Config
-	if config["insecure_ssl"] == "1" {
-		config["insecure_ssl"] = true
-	} else {
-		config["insecure_ssl"] = false
-	}
+// RepositoryWebhookData is passed to the github_repository_webhook template
+type RepositoryWebhookData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// ID is the webhook ID
+	ID int64
+	// Active is true when the webhook is triggered
+	Active bool
+	// Events are the events triggering the webhook
+	Events []string
+	// URL is the payload URL
+	URL string
+	// ContentType is the payload content type
+	ContentType string
+	// InsecureSSL is true when the SSL certificate isn't verified
+	InsecureSSL bool
+	// Secret is true when the webhook has a secret
+	Secret bool
+}
 
-	// Github will never return the actual secret
-	// https://github.com/terraform-providers/terraform-provider-github/blob/6a83f820a9776793a3b3ddd6c13c176059fc983a/github/resource_github_repository_webhook.go#L115-L117
-	// So let's just check if there's a secret to have a dummy value on the generated code
-	// The actual secret needs to be retrived directly from the website and updated in code
-	if config["secret"] != nil {
-		config["secret"] = true
-	} else {
-		config["secret"] = false
-	}
+func (s *session) repositoryWebhookResource(repo *github.Repository, webhook *github.Hook) *Resource {
+	url, _ := webhook.Config["url"].(string)
+	contentType, _ := webhook.Config["content_type"].(string)
 
-	tmpl := template.Must(template.New("repository-webhook").Funcs(templateFuncMap).Parse(repositoryWebhookTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org      string
-			RepoName string
-			ID       int64
-			Active   bool
-			Events   []string
-			// Config
-			URL         string
-			ContentType string
-			InsecureSSL bool
-			Secret      bool
-		}{
-			Org:         orgName,
+	return &Resource{
+		Type:     "github_repository_webhook",
+		Name:     fmt.Sprintf("%s-%d", s.normalizeResourceName(repo.GetName()), webhook.GetID()),
+		ImportID: fmt.Sprintf("%s/%d", repo.GetName(), webhook.GetID()),
+		Data: RepositoryWebhookData{
+			Org:         s.orgName,
 			RepoName:    repo.GetName(),
 			ID:          webhook.GetID(),
 			Active:      webhook.GetActive(),
 			Events:      webhook.Events,
-			URL:         config["url"].(string),
-			ContentType: config["content_type"].(string),
-			InsecureSSL: config["insecure_ssl"].(bool),
-			Secret:      config["secret"].(bool),
-		})
-	if err != nil {
-		log.Error(err)
+			URL:         url,
+			ContentType: contentType,
+			InsecureSSL: fmt.Sprintf("%v", webhook.Config["insecure_ssl"]) == "1",
+			// Github will never return the actual secret
+			// https://github.com/terraform-providers/terraform-provider-github/blob/6a83f820a9776793a3b3ddd6c13c176059fc983a/github/resource_github_repository_webhook.go#L115-L117
+			// So let's just check if there's a secret to have a dummy value on the generated code
+			// The actual secret needs to be retrived directly from the website and updated in code
+			Secret: webhook.Config["secret"] != nil,
+		},
 	}
 }
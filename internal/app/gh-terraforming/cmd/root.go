@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
@@ -16,7 +19,9 @@ import (
 var ctx = context.Background()
 var log = logrus.New()
 var orgName, apiToken, logLevel, outDirectory string
-var verbose bool
+var verbose, authoritative, userMode bool
+var apiBackend, cacheDirectory, fromSnapshot, templateDirectory, outputFormat string
+var cacheTTL time.Duration
 var api *github.Client
 
 // rootCmd represents the base command when called without any subcommands
@@ -25,27 +30,37 @@ var rootCmd = &cobra.Command{
 	Short: "Bootstrapping Terraform from existing Github organization",
 	Long: `gh-terraforming is an application that allows teams to start
 using Terraform by describing and importing existing resources in Github.`,
-	PersistentPreRun:  persistentPreRun,
+	// Execute logs the errors
+	SilenceErrors:     true,
+	PersistentPreRunE: persistentPreRun,
 	PersistentPostRun: persistentPostRun,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	// cobra and viper are set up here rather than in init, so importing the package as a library
+	// doesn't touch their global state
+	cobra.OnInitialize(initConfig)
+
+	viper.BindPFlag("token", rootCmd.PersistentFlags().Lookup("token"))
+	viper.BindEnv("token", "TOKEN")
+
+	viper.BindPFlag("organization", rootCmd.PersistentFlags().Lookup("organization"))
+	viper.BindEnv("organization", "ORGANIZATION")
+
 	if err := rootCmd.Execute(); err != nil {
 		log.Error(err)
-		return
+		os.Exit(1)
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-
 	// Personal access token
 	rootCmd.PersistentFlags().StringVarP(&apiToken, "token", "t", "", "Github Token")
 
 	// Organization
-	rootCmd.PersistentFlags().StringVarP(&orgName, "organization", "o", "", "Scope operations to this organization")
+	rootCmd.PersistentFlags().StringSliceVarP(&organizations, "organization", "o", nil, "Scope operations to this organization, repeat or separate with commas to export several organizations into subdirectories")
 
 	// Output directory
 	rootCmd.PersistentFlags().StringVarP(&outDirectory, "out-dir", "d", "", "Write resource files to this directory (default to PWD)")
@@ -54,11 +69,30 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "loglevel", "l", "", "Specify logging level: (trace, debug, info, warn, error, fatal, panic)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Specify verbose output (same as setting log level to debug)")
 
-	viper.BindPFlag("token", rootCmd.PersistentFlags().Lookup("token"))
-	viper.BindEnv("token", "TOKEN")
+	// Authoritative resources
+	rootCmd.PersistentFlags().BoolVar(&authoritative, "authoritative", false, "Emit one authoritative resource per repository or team (github_issue_labels, github_team_members, github_repository_collaborators) instead of one resource per item")
 
-	viper.BindPFlag("organization", rootCmd.PersistentFlags().Lookup("organization"))
-	viper.BindEnv("organization", "ORGANIZATION")
+	// API used to fetch the organization data
+	rootCmd.PersistentFlags().StringVar(&apiBackend, "api", "rest", "Fetch data with this Github API: (rest, graphql)")
+
+	// Response cache
+	rootCmd.PersistentFlags().StringVar(&cacheDirectory, "cache-dir", "", "Cache API responses in this directory and revalidate them with ETags (disabled by default)")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0, "Serve cached responses younger than this without revalidating them, e.g. 10m")
+
+	// Offline generation
+	rootCmd.PersistentFlags().StringVar(&fromSnapshot, "from-snapshot", "", "Read the organization data from a snapshot archive instead of the Github API")
+
+	// Output format
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", "hcl", "Write resources in this format: (hcl, json), json writes Terraform JSON *.tf.json files with import blocks")
+
+	// Template overrides
+	rootCmd.PersistentFlags().StringVar(&templateDirectory, "template-dir", "", "Render resources with the <resource type>.tmpl templates of this directory instead of the built-in ones, see templates dump")
+
+	// Personal account
+	rootCmd.PersistentFlags().BoolVar(&userMode, "user", false, "Import the repositories of the authenticated user instead of an organization, organization only resources are skipped")
+
+	// Configuration file
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Read settings from this file (default to .gh-terraforming.yaml in PWD when it exists)")
 }
 
 // initConfig reads in ENV variables if set.
@@ -66,6 +100,8 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 	viper.SetEnvPrefix("github")
 
+	configErr = loadConfig(rootCmd.PersistentFlags())
+
 	var cfgLogLevel = logrus.InfoLevel
 
 	// A user may also pass the verbose flag in order to support this convention
@@ -91,39 +127,128 @@ func initConfig() {
 	}
 
 	log.SetLevel(cfgLogLevel)
+
+	if file := viper.ConfigFileUsed(); file != "" {
+		log.WithFields(logrus.Fields{
+			"File": file,
+		}).Debug("Read configuration file")
+	}
 }
 
 // This function runs before every root command
-func persistentPreRun(cmd *cobra.Command, args []string) {
+func persistentPreRun(cmd *cobra.Command, args []string) error {
+	// the flags were parsed, errors from now on aren't usage errors
+	cmd.SilenceUsage = true
+
+	if configErr != nil {
+		return fmt.Errorf("configuration file: %v", configErr)
+	}
 
 	if cmd.Name() != "version" {
 
+		if outDirectory == "" {
+			outDirectory, _ = os.Getwd()
+		}
+
+		// comparing snapshots and dumping templates don't need any organization data
+		if cmd.Name() == "diff-snapshots" || cmd.Parent() == templatesCmd {
+			return nil
+		}
+
+		if outputFormat != "hcl" && outputFormat != "json" {
+			return fmt.Errorf("--format must be either hcl or json, got %s", outputFormat)
+		}
+
+		if userMode && fromSnapshot != "" {
+			return errors.New("--user can't be used with --from-snapshot, snapshots hold organization data")
+		}
+
+		if fromSnapshot != "" {
+			var err error
+			if archive, err = readSnapshot(fromSnapshot); err != nil {
+				return err
+			}
+
+			if organizations = organizationList(); len(organizations) > 1 {
+				return errors.New("--from-snapshot reads a single organization")
+			}
+			if len(organizations) == 1 && organizations[0] != archive.Organization {
+				return fmt.Errorf("the snapshot belongs to the %s organization, not %s", archive.Organization, organizations[0])
+			}
+			orgName = archive.Organization
+
+			log.WithFields(logrus.Fields{
+				"Snapshot":     fromSnapshot,
+				"Organization": orgName,
+				"CreatedAt":    archive.CreatedAt,
+			}).Debug("Reading from snapshot")
+			return nil
+		}
+
 		if apiToken = viper.GetString("token"); apiToken == "" {
-			log.Error("-t/--token option or GITHUB_TOKEN env var must be set")
-			return
+			return errors.New("-t/--token option or GITHUB_TOKEN env var must be set")
 		}
 
-		if orgName = viper.GetString("organization"); orgName == "" {
-			log.Error("-o/--organization option or GITHUB_ORGANIZATION env var must be set")
-			return
+		if organizations = organizationList(); userMode && len(organizations) > 0 {
+			return errors.New("--user can't be used with -o/--organization")
+		}
+		if !userMode && len(organizations) == 0 {
+			return errors.New("-o/--organization option or GITHUB_ORGANIZATION env var must be set")
+		}
+		if !userMode {
+			orgName = organizations[0]
+		}
+
+		if userMode && apiBackend == "graphql" {
+			return errors.New("--user only supports the rest API")
+		}
+
+		if apiBackend != "rest" && apiBackend != "graphql" {
+			return fmt.Errorf("--api must be either rest or graphql, got %s", apiBackend)
 		}
 
 		log.WithFields(logrus.Fields{
-			"Token":        fmt.Sprintf("*************%s", apiToken[:4]),
-			"Organization": orgName,
+			"Token":         fmt.Sprintf("*************%s", apiToken[:4]),
+			"Organizations": strings.Join(organizations, ","),
+			"API":           apiBackend,
 		}).Debug("Initializing go-github")
 
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: apiToken},
 		)
-		tc := oauth2.NewClient(ctx, ts)
+		clientCtx := ctx
+		if cacheDirectory != "" {
+			transport, err := newCacheTransport(cacheDirectory, cacheTTL, http.DefaultTransport)
+			if err != nil {
+				return err
+			}
+
+			log.WithFields(logrus.Fields{
+				"Directory": cacheDirectory,
+				"TTL":       cacheTTL,
+			}).Debug("Caching API responses")
+
+			clientCtx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+		}
+		tc := oauth2.NewClient(clientCtx, ts)
 
 		api = github.NewClient(tc)
 
-		if outDirectory == "" {
-			outDirectory, _ = os.Getwd()
+		// the authenticated user owns the repositories, its login takes the place of the organization
+		if userMode {
+			user, _, err := api.Users.Get(ctx, "")
+			if err != nil {
+				return err
+			}
+			orgName = user.GetLogin()
+
+			log.WithFields(logrus.Fields{
+				"User": orgName,
+			}).Debug("Importing personal account")
 		}
 	}
+
+	return nil
 }
 
 // This function runs following every root command
@@ -0,0 +1,643 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// rulesetConditionsTemplate and rulesetRulesTemplate are shared between repository and
+// organization rulesets, which only differ in the conditions they support
+const rulesetConditionsTemplate = `
+{{- define "ruleset-conditions"}}
+  {{- with .Ruleset.Conditions}}
+
+  conditions {
+    {{- with .RefName}}
+    ref_name {
+      include = {{hclValue .Include}}
+      exclude = {{hclValue .Exclude}}
+    }
+    {{- end}}
+    {{- with .RepositoryName}}
+    repository_name {
+      include   = {{hclValue .Include}}
+      exclude   = {{hclValue .Exclude}}
+      {{- attr "protected" .Protected false}}
+    }
+    {{- end}}
+    {{- with .RepositoryID}}
+    repository_id = {{hclValue .RepositoryIDs}}
+    {{- end}}
+  }
+  {{- end}}
+{{- end}}
+
+{{- define "ruleset-bypass-actors"}}
+  {{- range .BypassActors}}
+
+  bypass_actors {
+    actor_id    = {{.ActorID}}
+    actor_type  = {{hclValue .ActorType}}
+    bypass_mode = {{hclValue .BypassMode}}
+  }
+  {{- end}}
+{{- end}}
+`
+
+const rulesetRulesTemplate = `
+{{- define "ruleset-pattern"}}
+      {{- attr "name" .Name ""}}
+      {{- attr "negate" .Negate false}}
+      operator = {{hclValue .Operator}}
+      pattern  = {{hclValue .Pattern}}
+{{- end}}
+
+{{- define "ruleset-rules"}}
+
+  rules {
+    {{- attr "creation" (index .RuleTypes "creation") false}}
+    {{- attr "update" (index .RuleTypes "update") false}}
+    {{- with index .Rules "update"}}
+    {{- attr "update_allows_fetch_and_merge" .UpdateAllowsFetchAndMerge false}}
+    {{- end}}
+    {{- attr "deletion" (index .RuleTypes "deletion") false}}
+    {{- attr "required_linear_history" (index .RuleTypes "required_linear_history") false}}
+    {{- attr "required_signatures" (index .RuleTypes "required_signatures") false}}
+    {{- attr "non_fast_forward" (index .RuleTypes "non_fast_forward") false}}
+    {{- with index .Rules "required_deployments"}}
+
+    required_deployments {
+      required_deployment_environments = {{hclValue .RequiredDeploymentEnvironments}}
+    }
+    {{- end}}
+    {{- with index .Rules "pull_request"}}
+
+    pull_request {
+      {{- attr "dismiss_stale_reviews_on_push" .DismissStaleReviewsOnPush false}}
+      {{- attr "require_code_owner_review" .RequireCodeOwnerReview false}}
+      {{- attr "require_last_push_approval" .RequireLastPushApproval false}}
+      {{- attr "required_approving_review_count" .RequiredApprovingReviewCount 0}}
+      {{- attr "required_review_thread_resolution" .RequiredReviewThreadResolution false}}
+    }
+    {{- end}}
+    {{- with index .Rules "required_status_checks"}}
+
+    required_status_checks {
+      {{- attr "strict_required_status_checks_policy" .StrictRequiredStatusChecksPolicy false}}
+      {{- range .RequiredStatusChecks}}
+
+      required_check {
+        context        = {{hclValue .Context}}
+        {{- attr "integration_id" .IntegrationID 0}}
+      }
+      {{- end}}
+      {{- attr "do_not_enforce_on_create" .DoNotEnforceOnCreate false}}
+    }
+    {{- end}}
+    {{- with index .Rules "required_workflows"}}
+
+    required_workflows {
+      {{- range .Workflows}}
+      required_workflow {
+        repository_id = {{hclValue .RepositoryID}}
+        path          = {{hclValue .Path}}
+        {{- attr "ref" .Ref "master"}}
+      }
+      {{- end}}
+      {{- attr "do_not_enforce_on_create" .DoNotEnforceOnCreate false}}
+    }
+    {{- end}}
+    {{- with index .Rules "required_code_scanning"}}
+
+    required_code_scanning {
+      {{- range .CodeScanningTools}}
+      required_code_scanning_tool {
+        alert_threshold           = {{hclValue .AlertThreshold}}
+        security_alerts_threshold = {{hclValue .SecurityAlertsThreshold}}
+        tool                      = {{hclValue .Tool}}
+      }
+      {{- end}}
+    }
+    {{- end}}
+    {{- with index .Rules "merge_queue"}}
+
+    merge_queue {
+      check_response_timeout_minutes    = {{hclValue .CheckResponseTimeoutMinutes}}
+      grouping_strategy                 = {{hclValue .GroupingStrategy}}
+      max_entries_to_build              = {{hclValue .MaxEntriesToBuild}}
+      max_entries_to_merge              = {{hclValue .MaxEntriesToMerge}}
+      merge_method                      = {{hclValue .MergeMethod}}
+      min_entries_to_merge              = {{hclValue .MinEntriesToMerge}}
+      min_entries_to_merge_wait_minutes = {{hclValue .MinEntriesToMergeWaitMinutes}}
+    }
+    {{- end}}
+    {{- with index .Rules "file_path_restriction"}}
+
+    file_path_restriction {
+      restricted_file_paths = {{hclValue .RestrictedFilePaths}}
+    }
+    {{- end}}
+    {{- with index .Rules "file_extension_restriction"}}
+
+    file_extension_restriction {
+      restricted_file_extensions = {{hclValue .RestrictedFileExtensions}}
+    }
+    {{- end}}
+    {{- with index .Rules "max_file_size"}}
+
+    max_file_size {
+      max_file_size = {{hclValue .MaxFileSize}}
+    }
+    {{- end}}
+    {{- with index .Rules "max_file_path_length"}}
+
+    max_file_path_length {
+      max_file_path_length = {{hclValue .MaxFilePathLength}}
+    }
+    {{- end}}
+    {{- range $type := .PatternRuleTypes}}
+    {{- with index $.Rules $type}}
+
+    {{$type}} {
+      {{- template "ruleset-pattern" .}}
+    }
+    {{- end}}
+    {{- end}}
+  }
+{{- end}}
+`
+
+const repositoryRulesetTemplate = `
+{{- if hasLeadingDigit .RepoName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .RepoName}}-{{normalizeResourceName .Ruleset.Name}}
+{{- end}}
+{{- range .UnsupportedRules}}
+# WARNING the {{.}} rule of this ruleset can't be represented by the resource and is left out, please review it
+{{- end}}
+# terraform import github_repository_ruleset.{{normalizeResourceName .RepoName}}-{{normalizeResourceName .Ruleset.Name}} {{.RepoName}}:{{.Ruleset.ID}}
+resource "github_repository_ruleset" "{{normalizeResourceName .RepoName}}-{{normalizeResourceName .Ruleset.Name}}" {
+  name        = {{hclValue .Ruleset.Name}}
+  repository  = {{hclValue .RepoName}}
+  target      = {{hclValue .Ruleset.Target}}
+  enforcement = {{hclValue .Ruleset.Enforcement}}
+  {{- template "ruleset-conditions" .}}
+  {{- template "ruleset-bypass-actors" .}}
+  {{- template "ruleset-rules" .}}
+}
+`
+
+const organizationRulesetTemplate = `
+{{- if hasLeadingDigit .Ruleset.Name}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .Ruleset.Name}}
+{{- end}}
+{{- range .UnsupportedRules}}
+# WARNING the {{.}} rule of this ruleset can't be represented by the resource and is left out, please review it
+{{- end}}
+# terraform import github_organization_ruleset.{{normalizeResourceName .Ruleset.Name}} {{.Ruleset.ID}}
+resource "github_organization_ruleset" "{{normalizeResourceName .Ruleset.Name}}" {
+  name        = {{hclValue .Ruleset.Name}}
+  target      = {{hclValue .Ruleset.Target}}
+  enforcement = {{hclValue .Ruleset.Enforcement}}
+  {{- template "ruleset-conditions" .}}
+  {{- template "ruleset-bypass-actors" .}}
+  {{- template "ruleset-rules" .}}
+}
+`
+
+// Ruleset types, go-github doesn't support the rulesets API yet so we model
+// the parts of the payload that can be represented in Terraform
+type Ruleset struct {
+	ID           int64                `json:"id"`
+	Name         string               `json:"name"`
+	Target       string               `json:"target"`
+	SourceType   string               `json:"source_type"`
+	Source       string               `json:"source"`
+	Enforcement  string               `json:"enforcement"`
+	BypassActors []RulesetBypassActor `json:"bypass_actors"`
+	Conditions   *RulesetConditions   `json:"conditions"`
+	Rules        []RulesetRule        `json:"rules"`
+}
+
+type RulesetBypassActor struct {
+	ActorID    int64  `json:"actor_id"`
+	ActorType  string `json:"actor_type"`
+	BypassMode string `json:"bypass_mode"`
+}
+
+type RulesetConditions struct {
+	RefName        *RulesetRefNameCondition        `json:"ref_name"`
+	RepositoryName *RulesetRepositoryNameCondition `json:"repository_name"`
+	RepositoryID   *RulesetRepositoryIDCondition   `json:"repository_id"`
+}
+
+type RulesetRefNameCondition struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+type RulesetRepositoryNameCondition struct {
+	Include   []string `json:"include"`
+	Exclude   []string `json:"exclude"`
+	Protected bool     `json:"protected"`
+}
+
+type RulesetRepositoryIDCondition struct {
+	RepositoryIDs []int64 `json:"repository_ids"`
+}
+
+type RulesetRule struct {
+	Type       string                `json:"type"`
+	Parameters RulesetRuleParameters `json:"parameters"`
+}
+
+// RulesetRuleParameters is the union of the parameters of every rule type
+type RulesetRuleParameters struct {
+	// update
+	UpdateAllowsFetchAndMerge bool `json:"update_allows_fetch_and_merge"`
+	// required_deployments
+	RequiredDeploymentEnvironments []string `json:"required_deployment_environments"`
+	// pull_request
+	DismissStaleReviewsOnPush      bool `json:"dismiss_stale_reviews_on_push"`
+	RequireCodeOwnerReview         bool `json:"require_code_owner_review"`
+	RequireLastPushApproval        bool `json:"require_last_push_approval"`
+	RequiredApprovingReviewCount   int  `json:"required_approving_review_count"`
+	RequiredReviewThreadResolution bool `json:"required_review_thread_resolution"`
+	// required_status_checks and required_workflows
+	RequiredStatusChecks             []RulesetStatusCheck `json:"required_status_checks"`
+	StrictRequiredStatusChecksPolicy bool                 `json:"strict_required_status_checks_policy"`
+	DoNotEnforceOnCreate             bool                 `json:"do_not_enforce_on_create"`
+	Workflows                        []RulesetWorkflow    `json:"workflows"`
+	// required_code_scanning
+	CodeScanningTools []RulesetCodeScanningTool `json:"code_scanning_tools"`
+	// merge_queue
+	CheckResponseTimeoutMinutes  int    `json:"check_response_timeout_minutes"`
+	GroupingStrategy             string `json:"grouping_strategy"`
+	MaxEntriesToBuild            int    `json:"max_entries_to_build"`
+	MaxEntriesToMerge            int    `json:"max_entries_to_merge"`
+	MergeMethod                  string `json:"merge_method"`
+	MinEntriesToMerge            int    `json:"min_entries_to_merge"`
+	MinEntriesToMergeWaitMinutes int    `json:"min_entries_to_merge_wait_minutes"`
+	// file_path_restriction, file_extension_restriction, max_file_size and max_file_path_length
+	RestrictedFilePaths      []string `json:"restricted_file_paths"`
+	RestrictedFileExtensions []string `json:"restricted_file_extensions"`
+	MaxFileSize              int64    `json:"max_file_size"`
+	MaxFilePathLength        int      `json:"max_file_path_length"`
+	// *_pattern
+	Name     string `json:"name"`
+	Negate   bool   `json:"negate"`
+	Operator string `json:"operator"`
+	Pattern  string `json:"pattern"`
+}
+
+type RulesetStatusCheck struct {
+	Context       string `json:"context"`
+	IntegrationID int64  `json:"integration_id"`
+}
+
+type RulesetWorkflow struct {
+	Path         string `json:"path"`
+	Ref          string `json:"ref"`
+	RepositoryID int64  `json:"repository_id"`
+}
+
+type RulesetCodeScanningTool struct {
+	AlertThreshold          string `json:"alert_threshold"`
+	SecurityAlertsThreshold string `json:"security_alerts_threshold"`
+	Tool                    string `json:"tool"`
+}
+
+// rulesetPatternRuleTypes are the rules that share the pattern parameters, in the order they are rendered
+var rulesetPatternRuleTypes = []string{
+	"commit_message_pattern",
+	"commit_author_email_pattern",
+	"committer_email_pattern",
+	"branch_name_pattern",
+	"tag_name_pattern",
+}
+
+// repositoryRulesetRuleTypes are the rule types github_repository_ruleset supports besides the pattern rules
+var repositoryRulesetRuleTypes = []string{
+	"creation",
+	"update",
+	"deletion",
+	"required_linear_history",
+	"required_signatures",
+	"non_fast_forward",
+	"required_deployments",
+	"pull_request",
+	"required_status_checks",
+	"required_code_scanning",
+	"merge_queue",
+	"file_path_restriction",
+	"file_extension_restriction",
+	"max_file_size",
+	"max_file_path_length",
+}
+
+// organizationRulesetRuleTypes are the rule types github_organization_ruleset supports besides the pattern rules
+var organizationRulesetRuleTypes = []string{
+	"creation",
+	"update",
+	"deletion",
+	"required_linear_history",
+	"required_signatures",
+	"non_fast_forward",
+	"pull_request",
+	"required_status_checks",
+	"required_workflows",
+	"required_code_scanning",
+	"file_path_restriction",
+	"file_extension_restriction",
+	"max_file_size",
+	"max_file_path_length",
+}
+
+var rulesetCmd = RegisterGenerator(&generator{
+	name:  "ruleset",
+	fetch: (*session).rulesetFetch,
+	templates: map[string]string{
+		"github_organization_ruleset": organizationRulesetTemplate,
+		"github_repository_ruleset":   repositoryRulesetTemplate,
+	},
+	shared: map[string]string{
+		"ruleset-conditions": rulesetConditionsTemplate,
+		"ruleset-rules":      rulesetRulesTemplate,
+	},
+}, "Import repository and organization rulesets into Terraform")
+
+func (s *session) rulesetFetch() ([]*Resource, error) {
+	s.log.Debug("Getting ruleset data")
+
+	var resources []*Resource
+	var teams []*github.Team
+	if !s.userMode {
+		var err error
+
+		// teams are used to reference the github_team resources generated in the same run from bypass actors
+		if s.generators["team"] {
+			if teams, err = s.getOrgTeams(); err != nil {
+				return nil, err
+			}
+		}
+
+		orgRulesets, err := s.getOrganizationRulesets()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rs := range orgRulesets {
+			s.log.WithFields(logrus.Fields{
+				"Ruleset": rs.Name,
+			}).Debug("Processing organization ruleset")
+
+			resources = append(resources, s.organizationRulesetResource(rs, teams))
+		}
+	}
+
+	// first get repositories, then for each repo, get its rulesets
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, repo := range repos {
+
+		rulesets, err := s.getRepositoryRulesets(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rs := range rulesets {
+			s.log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+				"Ruleset":    rs.Name,
+			}).Debug("Processing repository ruleset")
+
+			resources = append(resources, s.repositoryRulesetResource(repo, rs, teams))
+		}
+	}
+
+	return resources, nil
+}
+
+func (s *session) getOrganizationRulesets() ([]*Ruleset, error) {
+	if s.archive != nil {
+		return s.archive.OrganizationRulesets, nil
+	}
+
+	return s.getRulesets(fmt.Sprintf("orgs/%s/rulesets", s.orgName))
+}
+
+func (s *session) getRepositoryRulesets(repo *github.Repository) ([]*Ruleset, error) {
+	if s.archive != nil {
+		return s.archive.repository(repo).Rulesets, nil
+	}
+
+	// Rulesets inherited from the organization are exported by getOrganizationRulesets
+	return s.getRulesets(fmt.Sprintf("repos/%s/%s/rulesets", s.orgName, repo.GetName()))
+}
+
+// getRulesets lists the rulesets under the given path and then fetches each one of them,
+// as only the detailed endpoint returns the conditions, rules and bypass actors
+func (s *session) getRulesets(path string) ([]*Ruleset, error) {
+	opt := &github.ListOptions{PerPage: 100, Page: 1}
+
+	var summaries []*Ruleset
+	for {
+		req, err := s.api.NewRequest("GET", fmt.Sprintf("%s?includes_parents=false&per_page=%d&page=%d", path, opt.PerPage, opt.Page), nil)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		var rulesets []*Ruleset
+		resp, err := s.api.Do(s.ctx, req, &rulesets)
+		if err != nil {
+			// Rulesets are not available on every plan, that shouldn't stop the whole import
+			if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+				s.log.WithFields(logrus.Fields{
+					"Path": path,
+				}).Warn("Rulesets are not available")
+				return nil, nil
+			}
+			s.log.Error(err)
+			return nil, err
+		}
+
+		summaries = append(summaries, rulesets...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		s.log.Debugf("Fetching next page %d", opt.Page)
+	}
+
+	var allRulesets []*Ruleset
+	for _, summary := range summaries {
+		req, err := s.api.NewRequest("GET", fmt.Sprintf("%s/%d", path, summary.ID), nil)
+		if err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		rs := new(Ruleset)
+		if _, err := s.api.Do(s.ctx, req, rs); err != nil {
+			s.log.Error(err)
+			return nil, err
+		}
+
+		allRulesets = append(allRulesets, rs)
+	}
+
+	return allRulesets, nil
+}
+
+// rulesetBypassActors maps bypass teams to their github_team resource when it's generated in the same run,
+// the other actors keep their ID
+func (s *session) rulesetBypassActors(rs *Ruleset, teams []*github.Team) []RulesetBypassActorData {
+	var actors []RulesetBypassActorData
+	for _, actor := range rs.BypassActors {
+		actorID := strconv.FormatInt(actor.ActorID, 10)
+
+		if actor.ActorType == "Team" {
+			for _, team := range teams {
+				name := s.normalizeResourceName(team.GetName())
+				if team.GetID() == actor.ActorID && s.generates("team", "github_team", name) {
+					actorID = fmt.Sprintf("github_team.%s.id", name)
+					break
+				}
+			}
+		}
+
+		actors = append(actors, RulesetBypassActorData{
+			ActorID:    actorID,
+			ActorType:  actor.ActorType,
+			BypassMode: actor.BypassMode,
+		})
+	}
+
+	return actors
+}
+
+// RulesetBypassActorData is a bypass actor of the ruleset templates, ActorID is either the ID or a
+// reference to the generated github_team resource
+type RulesetBypassActorData struct {
+	ActorID    string
+	ActorType  string
+	BypassMode string
+}
+
+// rulesetRulesByType indexes the rules the resource supports by type and returns the types of the other ones,
+// which would otherwise be silently dropped
+func (s *session) rulesetRulesByType(rs *Ruleset, supported []string) (map[string]*RulesetRuleParameters, []string) {
+	rules := make(map[string]*RulesetRuleParameters)
+	var unsupported []string
+	for i := range rs.Rules {
+		ruleType := rs.Rules[i].Type
+		if !contains(supported, ruleType) && !contains(rulesetPatternRuleTypes, ruleType) {
+			s.log.WithFields(logrus.Fields{
+				"Ruleset": rs.Name,
+				"Rule":    ruleType,
+			}).Warn("Rule type not supported, leaving it out")
+
+			unsupported = append(unsupported, ruleType)
+			continue
+		}
+
+		rules[ruleType] = &rs.Rules[i].Parameters
+	}
+
+	return rules, unsupported
+}
+
+func rulesetRuleTypes(rules map[string]*RulesetRuleParameters) map[string]bool {
+	types := make(map[string]bool)
+	for ruleType := range rules {
+		types[ruleType] = true
+	}
+
+	return types
+}
+
+// RepositoryRulesetData is passed to the github_repository_ruleset template
+type RepositoryRulesetData struct {
+	// Org is the organization name
+	Org string
+	// RepoName is the repository name
+	RepoName string
+	// Ruleset is the Github ruleset
+	Ruleset Ruleset
+	// BypassActors are the actors allowed to bypass the ruleset
+	BypassActors []RulesetBypassActorData
+	// Rules are the rule parameters indexed by rule type
+	Rules map[string]*RulesetRuleParameters
+	// RuleTypes is the set of the rule types of the ruleset the resource supports
+	RuleTypes map[string]bool
+	// PatternRuleTypes are the rules sharing the pattern parameters, in the order they are rendered
+	PatternRuleTypes []string
+	// UnsupportedRules are the types of the rules the resource can't represent
+	UnsupportedRules []string
+}
+
+func (s *session) repositoryRulesetResource(repo *github.Repository, rs *Ruleset, teams []*github.Team) *Resource {
+	rules, unsupported := s.rulesetRulesByType(rs, repositoryRulesetRuleTypes)
+
+	return &Resource{
+		Type:     "github_repository_ruleset",
+		Name:     fmt.Sprintf("%s-%s", s.normalizeResourceName(repo.GetName()), s.normalizeResourceName(rs.Name)),
+		ImportID: fmt.Sprintf("%s:%d", repo.GetName(), rs.ID),
+		Data: RepositoryRulesetData{
+			Org:              s.orgName,
+			RepoName:         repo.GetName(),
+			Ruleset:          *rs,
+			BypassActors:     s.rulesetBypassActors(rs, teams),
+			Rules:            rules,
+			RuleTypes:        rulesetRuleTypes(rules),
+			PatternRuleTypes: rulesetPatternRuleTypes,
+			UnsupportedRules: unsupported,
+		},
+	}
+}
+
+// OrganizationRulesetData is passed to the github_organization_ruleset template
+type OrganizationRulesetData struct {
+	// Org is the organization name
+	Org string
+	// Ruleset is the Github ruleset
+	Ruleset Ruleset
+	// BypassActors are the actors allowed to bypass the ruleset
+	BypassActors []RulesetBypassActorData
+	// Rules are the rule parameters indexed by rule type
+	Rules map[string]*RulesetRuleParameters
+	// RuleTypes is the set of the rule types of the ruleset the resource supports
+	RuleTypes map[string]bool
+	// PatternRuleTypes are the rules sharing the pattern parameters, in the order they are rendered
+	PatternRuleTypes []string
+	// UnsupportedRules are the types of the rules the resource can't represent
+	UnsupportedRules []string
+}
+
+func (s *session) organizationRulesetResource(rs *Ruleset, teams []*github.Team) *Resource {
+	rules, unsupported := s.rulesetRulesByType(rs, organizationRulesetRuleTypes)
+
+	return &Resource{
+		Type:     "github_organization_ruleset",
+		Name:     s.normalizeResourceName(rs.Name),
+		ImportID: fmt.Sprintf("%d", rs.ID),
+		Data: OrganizationRulesetData{
+			Org:              s.orgName,
+			Ruleset:          *rs,
+			BypassActors:     s.rulesetBypassActors(rs, teams),
+			Rules:            rules,
+			RuleTypes:        rulesetRuleTypes(rules),
+			PatternRuleTypes: rulesetPatternRuleTypes,
+			UnsupportedRules: unsupported,
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// session holds the state of a run against one organization: the API client, the settings, the generator
+// options and what's cached along the way. The command line creates one per organization and every library
+// call creates its own, so runs don't share anything.
+type session struct {
+	ctx     context.Context
+	api     *github.Client
+	log     *logrus.Logger
+	orgName string
+	// userMode is set when orgName is the login of the authenticated user instead of an organization
+	userMode bool
+	// archive is set when the data is read from a snapshot instead of the API
+	archive *snapshot
+	// config holds the configuration file settings, nil when there's none
+	config *runConfig
+
+	apiBackend        string
+	authoritative     bool
+	outputFormat      string
+	templateDirectory string
+
+	// outDirectory is where the files are written, providerAlias is the provider alias the resources
+	// use when several organizations are exported in the same run
+	outDirectory  string
+	providerAlias string
+
+	// generator options
+	branchDefaultOnly      bool
+	branchProtectedOnly    bool
+	branchPattern          string
+	issueLabelSkipDefaults bool
+
+	// generators are the names of the generators of the run, resources only reference the resources
+	// generated in the same run
+	generators map[string]bool
+	// writtenFiles are the output files written during the run
+	writtenFiles map[string]bool
+	// graphqlRepositories and graphqlTeams cache the results of the GraphQL queries indexed by name and slug
+	graphqlRepositories map[string]*graphqlRepository
+	graphqlTeams        map[string]*graphqlTeam
+	// graphqlMembers caches the organization members along with their role
+	graphqlMembers *graphqlUserEdges
+	// teamImmediateMembers caches the immediate members of the teams indexed by team ID
+	teamImmediateMembers map[int64]map[string]bool
+}
+
+// newSession returns the session of the command line for the organization written to dir, from its
+// flags and settings
+func newSession(org, dir string) *session {
+	return &session{
+		ctx:                    ctx,
+		api:                    api,
+		log:                    log,
+		orgName:                org,
+		userMode:               userMode,
+		archive:                archive,
+		config:                 config,
+		apiBackend:             apiBackend,
+		authoritative:          authoritative,
+		outputFormat:           outputFormat,
+		templateDirectory:      templateDirectory,
+		outDirectory:           dir,
+		branchDefaultOnly:      branchDefaultOnly,
+		branchProtectedOnly:    branchProtectedOnly,
+		branchPattern:          branchPattern,
+		issueLabelSkipDefaults: issueLabelSkipDefaults,
+		writtenFiles:           make(map[string]bool),
+	}
+}
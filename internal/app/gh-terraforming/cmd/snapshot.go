@@ -0,0 +1,507 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// snapshotVersion is bumped whenever the archive format changes in a backwards incompatible way
+const snapshotVersion = 1
+
+// snapshot holds everything the generators fetch from Github, so they can run from the archive
+// written by the snapshot command instead of the API
+type snapshot struct {
+	Version      int       `json:"version"`
+	Organization string    `json:"organization"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	Org                  *github.Organization      `json:"org"`
+	Members              map[string][]*github.User `json:"members"` // indexed by role
+	PendingInvitations   []*github.Invitation      `json:"pending_invitations"`
+	BlockedUsers         []*github.User            `json:"blocked_users"`
+	OrganizationRulesets []*Ruleset                `json:"organization_rulesets"`
+	Repositories         []*snapshotRepository     `json:"repositories"`
+	Teams                []*snapshotTeam           `json:"teams"`
+	TeamSyncUnavailable  bool                      `json:"team_sync_unavailable"`
+
+	// Actions permissions are indexed by their API path, as both organizations and repositories have them
+	ActionsPermissions         map[string]*ActionsPermissions     `json:"actions_permissions"`
+	ActionsSelectedActions     map[string]*ActionsSelectedActions `json:"actions_selected_actions"`
+	ActionsEnabledRepositories []*github.Repository               `json:"actions_enabled_repositories"`
+}
+
+type snapshotRepository struct {
+	Repository          *github.Repository               `json:"repository"`
+	Details             *RepositoryDetails               `json:"details"`
+	VulnerabilityAlerts *bool                            `json:"vulnerability_alerts"`
+	Pages               *RepositoryPages                 `json:"pages"`
+	Branches            []*github.Branch                 `json:"branches"`
+	BranchSources       map[string]*snapshotBranchSource `json:"branch_sources"` // indexed by branch name
+	Collaborators       map[string][]*github.User        `json:"collaborators"`  // indexed by affiliation, nil when they couldn't be read
+	Invitations         []*github.RepositoryInvitation   `json:"invitations"`
+	Teams               []*github.Team                   `json:"teams"`
+	Labels              []*github.Label                  `json:"labels"`
+	Webhooks            []*github.Hook                   `json:"webhooks"`
+	Rulesets            []*Ruleset                       `json:"rulesets"`
+	ActionsAccess       *actionsRepositoryAccess         `json:"actions_access"`
+}
+
+type snapshotBranchSource struct {
+	Branch string `json:"branch"`
+	SHA    string `json:"sha"`
+}
+
+type snapshotTeam struct {
+	Team             *github.Team              `json:"team"`
+	Members          map[string][]*github.User `json:"members"`           // indexed by role
+	ImmediateMembers []string                  `json:"immediate_members"` // logins of the team's own members, only kept for teams with child teams
+	Repositories     []*github.Repository      `json:"repositories"`
+	SyncGroups       []*github.IDPGroup        `json:"sync_groups"`
+	Settings         *TeamSettings             `json:"settings"`
+}
+
+// archive is set when the generators read from a snapshot with --from-snapshot
+var archive *snapshot
+
+var snapshotFile string
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.Flags().StringVarP(&snapshotFile, "file", "f", "", "Write the snapshot to this file (default to snapshot.json in the output directory)")
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save the organization data to a JSON archive that generators can read with --from-snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		if userMode {
+			log.Error("snapshot requires an organization, it can't be used with --user")
+			return
+		}
+
+		if snapshotFile != "" && len(organizations) > 1 {
+			log.Error("--file can't be used with several organizations")
+			return
+		}
+
+		forEachOrganization(func(s *session) {
+			s.log.Debug("Getting snapshot data")
+
+			snap, err := s.getSnapshot()
+			if err != nil {
+				return
+			}
+
+			path := snapshotFile
+			if path == "" {
+				path = filepath.Join(s.outDirectory, "snapshot.json")
+			}
+
+			if err := writeSnapshot(snap, path); err != nil {
+				s.log.Error(err)
+				return
+			}
+
+			s.log.WithFields(logrus.Fields{
+				"File":         path,
+				"Repositories": len(snap.Repositories),
+				"Teams":        len(snap.Teams),
+			}).Info("Snapshot saved")
+		})
+	},
+}
+
+// getSnapshot fetches the organization data with the same functions the generators use
+func (s *session) getSnapshot() (*snapshot, error) {
+	snap := &snapshot{
+		Version:                snapshotVersion,
+		Organization:           s.orgName,
+		CreatedAt:              time.Now().UTC(),
+		Members:                make(map[string][]*github.User),
+		ActionsPermissions:     make(map[string]*ActionsPermissions),
+		ActionsSelectedActions: make(map[string]*ActionsSelectedActions),
+	}
+
+	var err error
+	if snap.Org, err = s.getOrganization(); err != nil {
+		return nil, err
+	}
+
+	for _, role := range []string{"admin", "member"} {
+		if snap.Members[role], err = s.getOrgMembers(role); err != nil {
+			return nil, err
+		}
+	}
+
+	if snap.PendingInvitations, err = s.getOrgPendingInvitations(); err != nil {
+		return nil, err
+	}
+
+	if snap.BlockedUsers, err = s.getorganizationBlockedUsers(); err != nil {
+		return nil, err
+	}
+
+	if snap.OrganizationRulesets, err = s.getOrganizationRulesets(); err != nil {
+		return nil, err
+	}
+
+	if err := s.snapshotActions(snap, fmt.Sprintf("orgs/%s/actions/permissions", s.orgName)); err != nil {
+		return nil, err
+	}
+	if permissions := snap.ActionsPermissions[fmt.Sprintf("orgs/%s/actions/permissions", s.orgName)]; permissions != nil && permissions.EnabledRepositories == "selected" {
+		if snap.ActionsEnabledRepositories, err = s.getActionsEnabledRepositories(); err != nil {
+			return nil, err
+		}
+	}
+
+	repos, err := s.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, repo := range repos {
+		s.log.WithFields(logrus.Fields{
+			"Repository": repo.GetName(),
+		}).Debug("Processing repository snapshot")
+
+		snapshotRepo, err := s.getSnapshotRepository(snap, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		snap.Repositories = append(snap.Repositories, snapshotRepo)
+	}
+
+	teams, err := s.getOrgTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := s.getOrgTeamSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := newTeamHierarchy(teams)
+	for _, team := range teams {
+		s.log.WithFields(logrus.Fields{
+			"Team": team.GetName(),
+		}).Debug("Processing team snapshot")
+
+		snapshotTeam, err := s.getSnapshotTeam(snap, team, hierarchy)
+		if err != nil {
+			return nil, err
+		}
+		snapshotTeam.Settings = settings[team.GetSlug()]
+
+		snap.Teams = append(snap.Teams, snapshotTeam)
+	}
+
+	return snap, nil
+}
+
+func (s *session) getSnapshotRepository(snap *snapshot, repo *github.Repository) (*snapshotRepository, error) {
+	r := &snapshotRepository{
+		Repository:    repo,
+		BranchSources: make(map[string]*snapshotBranchSource),
+		Collaborators: make(map[string][]*github.User),
+	}
+
+	fullRepo, details, err := s.getRepositoryDetails(repo)
+	if err != nil {
+		return nil, err
+	}
+	r.Repository = fullRepo
+	r.Details = details
+	r.VulnerabilityAlerts = details.VulnerabilityAlerts
+	r.Pages = details.Pages
+
+	if r.Branches, err = s.getRepositoryBranches(repo); err != nil {
+		return nil, err
+	}
+	for _, branch := range r.Branches {
+		if branch.GetName() == repo.GetDefaultBranch() {
+			continue
+		}
+
+		sourceBranch, sourceSHA := s.getRepositoryBranchSource(repo, branch)
+		r.BranchSources[branch.GetName()] = &snapshotBranchSource{Branch: sourceBranch, SHA: sourceSHA}
+	}
+
+	// unreadable collaborators are kept as nil, which tells them apart from a repository without any
+	for _, affiliation := range []string{"outside", "direct"} {
+		collaborators, err := s.getOrgRepositoryCollaborators(repo, affiliation)
+		if err != nil && err != errCollaboratorsUnreadable {
+			return nil, err
+		}
+		r.Collaborators[affiliation] = collaborators
+	}
+
+	if r.Invitations, err = s.getRepositoryInvitations(repo); err != nil {
+		return nil, err
+	}
+
+	if r.Teams, err = s.getRepositoryTeams(repo); err != nil {
+		return nil, err
+	}
+
+	if r.Labels, err = s.getRepositoryIssueLabels(repo); err != nil {
+		return nil, err
+	}
+
+	if r.Webhooks, err = s.getRepositoryWebhooks(repo); err != nil {
+		return nil, err
+	}
+
+	if r.Rulesets, err = s.getRepositoryRulesets(repo); err != nil {
+		return nil, err
+	}
+
+	if err := s.snapshotActions(snap, fmt.Sprintf("repos/%s/%s/actions/permissions", s.orgName, repo.GetName())); err != nil {
+		return nil, err
+	}
+
+	// The access level only applies to private and internal repositories
+	if repo.GetPrivate() {
+		if r.ActionsAccess, err = s.getActionsRepositoryAccess(repo); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (s *session) getSnapshotTeam(snap *snapshot, team *github.Team, hierarchy *teamHierarchy) (*snapshotTeam, error) {
+	t := &snapshotTeam{
+		Team:    team,
+		Members: make(map[string][]*github.User),
+	}
+
+	var err error
+	for _, role := range []string{"maintainer", "member"} {
+		if t.Members[role], err = s.getOrgTeamMemberships(team, role); err != nil {
+			return nil, err
+		}
+	}
+
+	// the members of the team only differ from its immediate members when it has child teams
+	if len(hierarchy.childTeams(team)) > 0 {
+		immediate, err := s.getOrgTeamImmediateMembers(team)
+		if err != nil {
+			return nil, err
+		}
+
+		t.ImmediateMembers = make([]string, 0, len(immediate))
+		for login := range immediate {
+			t.ImmediateMembers = append(t.ImmediateMembers, login)
+		}
+		sort.Strings(t.ImmediateMembers)
+	}
+
+	if t.Repositories, err = s.getOrgTeamRepositorys(team); err != nil {
+		return nil, err
+	}
+
+	// there's no point in asking again once we know the organization doesn't use team synchronization
+	if !snap.TeamSyncUnavailable {
+		t.SyncGroups, err = s.getTeamSyncGroups(team)
+		if err == errTeamSyncUnavailable {
+			snap.TeamSyncUnavailable = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// snapshotActions saves the Actions permissions found under the API path
+func (s *session) snapshotActions(snap *snapshot, path string) error {
+	permissions, err := s.getActionsPermissions(path)
+	if err != nil {
+		return err
+	}
+	snap.ActionsPermissions[path] = permissions
+
+	selectedActions, err := s.getActionsSelectedActions(path, permissions)
+	if err != nil {
+		return err
+	}
+	snap.ActionsSelectedActions[path] = selectedActions
+
+	return nil
+}
+
+func writeSnapshot(s *snapshot, path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func readSnapshot(path string) (*snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(snapshot)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s is not a valid snapshot: %v", path, err)
+	}
+
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%s has snapshot version %d, only version %d is supported", path, s.Version, snapshotVersion)
+	}
+
+	return s, nil
+}
+
+// The following functions answer the generators fetch functions when reading from a snapshot
+
+func (s *snapshot) repositories() []*github.Repository {
+	var repos []*github.Repository
+	for _, r := range s.Repositories {
+		repos = append(repos, r.Repository)
+	}
+
+	return repos
+}
+
+// repository returns the snapshot of the repository, empty when the repository isn't in the snapshot
+func (s *snapshot) repository(repo *github.Repository) *snapshotRepository {
+	for _, r := range s.Repositories {
+		if r.Repository.GetName() == repo.GetName() {
+			return r
+		}
+	}
+
+	return &snapshotRepository{}
+}
+
+func (s *snapshot) repositoryDetails(repo *github.Repository) (*github.Repository, *RepositoryDetails, error) {
+	r := s.repository(repo)
+	if r.Repository == nil || r.Details == nil {
+		return nil, nil, fmt.Errorf("repository %s is not in the snapshot", repo.GetName())
+	}
+
+	details := *r.Details
+	details.VulnerabilityAlerts = r.VulnerabilityAlerts
+	details.Pages = r.Pages
+
+	return r.Repository, &details, nil
+}
+
+func (s *snapshot) repositoryBranches(repo *github.Repository, protectedOnly bool) []*github.Branch {
+	var branches []*github.Branch
+	for _, branch := range s.repository(repo).Branches {
+		if protectedOnly && !branch.GetProtected() {
+			continue
+		}
+
+		branches = append(branches, branch)
+	}
+
+	return branches
+}
+
+func (s *snapshot) repositoryBranchSource(repo *github.Repository, branch *github.Branch) (string, string) {
+	source, ok := s.repository(repo).BranchSources[branch.GetName()]
+	if !ok {
+		return "", ""
+	}
+
+	return source.Branch, source.SHA
+}
+
+func (s *snapshot) teams() []*github.Team {
+	var teams []*github.Team
+	for _, t := range s.Teams {
+		teams = append(teams, t.Team)
+	}
+
+	return teams
+}
+
+// team returns the snapshot of the team, empty when the team isn't in the snapshot
+func (s *snapshot) team(team *github.Team) *snapshotTeam {
+	for _, t := range s.Teams {
+		if t.Team.GetSlug() == team.GetSlug() {
+			return t
+		}
+	}
+
+	return &snapshotTeam{}
+}
+
+func (s *snapshot) teamMembers(team *github.Team, role string) []*github.User {
+	members := s.team(team).Members
+	if role != "all" {
+		return members[role]
+	}
+
+	return append(append([]*github.User{}, members["maintainer"]...), members["member"]...)
+}
+
+// teamImmediateMembers returns the members of the team itself indexed by login
+func (s *snapshot) teamImmediateMembers(team *github.Team) (map[string]bool, error) {
+	logins := s.team(team).ImmediateMembers
+	if logins == nil {
+		return nil, fmt.Errorf("the snapshot doesn't have the immediate members of the team %s, take it again to tell its inherited members apart", team.GetName())
+	}
+
+	members := make(map[string]bool)
+	for _, login := range logins {
+		members[login] = true
+	}
+
+	return members, nil
+}
+
+func (s *snapshot) teamSyncGroups(team *github.Team) ([]*github.IDPGroup, error) {
+	if s.TeamSyncUnavailable {
+		return nil, errTeamSyncUnavailable
+	}
+
+	return s.team(team).SyncGroups, nil
+}
+
+func (s *snapshot) teamSettings() map[string]*TeamSettings {
+	settings := make(map[string]*TeamSettings)
+	for _, t := range s.Teams {
+		if t.Settings != nil {
+			settings[t.Team.GetSlug()] = t.Settings
+		}
+	}
+
+	return settings
+}
+
+func (s *snapshot) members(role string) []*github.User {
+	if role != "all" {
+		return s.Members[role]
+	}
+
+	return append(append([]*github.User{}, s.Members["admin"]...), s.Members["member"]...)
+}
+
+// actionsPermissions returns nil when the permissions weren't available when the snapshot was taken
+func (s *snapshot) actionsPermissions(path string) (*ActionsPermissions, error) {
+	permissions, ok := s.ActionsPermissions[path]
+	if !ok {
+		return nil, fmt.Errorf("Actions permissions for %s are not in the snapshot", path)
+	}
+
+	return permissions, nil
+}
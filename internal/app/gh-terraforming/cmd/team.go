@@ -1,13 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"text/template"
+	"net/http"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const teamTemplate = `
@@ -17,58 +16,168 @@ const teamTemplate = `
 {{- end}}
 # terraform import github_team.{{normalizeResourceName .Team.Name}} {{.Team.ID}}
 resource "github_team" "{{normalizeResourceName .Team.Name}}" {
-  name           = "{{.Team.Name}}"
-  {{if .Team.Description}}description    = "{{.Team.Description}}"
-  {{end -}}
-  {{if .Team.Privacy}}privacy        = "{{.Team.Privacy}}"
-  {{end -}}
-  {{if .ParentID}}parent_team_id = "{{.ParentID}}"
-  {{end -}}
-  {{if .Team.LDAPDN}}ldap_dn        = "{{.Team.LDAPDN}}"
-  {{end -}}
+  name           = {{hclValue .Team.Name}}
+  {{- attr "description" .Team.Description ""}}
+  {{- attr "privacy" .Team.Privacy "secret"}}
+  {{- attr "parent_team_id" .ParentID 0}}
+  {{- attr "ldap_dn" .Team.LDAPDN ""}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(teamCmd)
+const teamSyncGroupMappingTemplate = `
+{{- if hasLeadingDigit .Team.Name}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .Team.Name}}
+{{- end}}
+# terraform import github_team_sync_group_mapping.{{normalizeResourceName .Team.Name}} {{.Team.Slug}}
+resource "github_team_sync_group_mapping" "{{normalizeResourceName .Team.Name}}" {
+  team_slug = {{hclValue .Team.Slug}}
+  {{- range .Groups}}
+
+  group {
+    group_id          = {{hclValue .GetGroupID}}
+    group_name        = {{hclValue .GetGroupName}}
+    {{- attr "group_description" .GetGroupDescription ""}}
+  }
+  {{- end}}
+}
+`
+
+const teamSettingsTemplate = `
+{{- if hasLeadingDigit .Team.Name}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .Team.Name}}
+{{- end}}
+# terraform import github_team_settings.{{normalizeResourceName .Team.Name}} {{.Team.ID}}
+resource "github_team_settings" "{{normalizeResourceName .Team.Name}}" {
+  team_id = {{hclValue .Team.ID}}
+
+  review_request_delegation {
+    algorithm    = {{hclValue .Settings.ReviewRequestDelegationAlgorithm}}
+    member_count = {{hclValue .Settings.ReviewRequestDelegationMemberCount}}
+    {{- attr "notify" .Settings.ReviewRequestDelegationNotifyTeam false}}
+  }
 }
+`
+
+// TeamSettings holds the code review assignment settings of a team, only available through GraphQL
+type TeamSettings struct {
+	Slug                               string `json:"slug"`
+	ReviewRequestDelegationEnabled     bool   `json:"reviewRequestDelegationEnabled"`
+	ReviewRequestDelegationAlgorithm   string `json:"reviewRequestDelegationAlgorithm"`
+	ReviewRequestDelegationMemberCount int    `json:"reviewRequestDelegationMemberCount"`
+	ReviewRequestDelegationNotifyTeam  bool   `json:"reviewRequestDelegationNotifyTeam"`
+}
+
+const teamSettingsQuery = `
+query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    teams(first: 100, after: $cursor) {
+      nodes {
+        slug
+        reviewRequestDelegationEnabled
+        reviewRequestDelegationAlgorithm
+        reviewRequestDelegationMemberCount
+        reviewRequestDelegationNotifyTeam
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+    }
+  }
+}
+`
+
+// errTeamSyncUnavailable is returned when the organization doesn't use team synchronization
+var errTeamSyncUnavailable = errors.New("team synchronization is not available for this organization")
+
+var teamCmd = RegisterGenerator(&generator{
+	name:  "team",
+	fetch: (*session).teamFetch,
+	templates: map[string]string{
+		"github_team":                    teamTemplate,
+		"github_team_sync_group_mapping": teamSyncGroupMappingTemplate,
+		"github_team_settings":           teamSettingsTemplate,
+	},
+	organizationOnly: true,
+}, "Import organization teams into Terraform")
+
+func (s *session) teamFetch() ([]*Resource, error) {
+	s.log.Debug("Getting team data")
+
+	teams, err := s.getOrgTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, team := range teams {
+		s.log.WithFields(logrus.Fields{
+			"Member": team.GetName(),
+		}).Debug("Processing team")
 
-var teamCmd = &cobra.Command{
-	Use:   "team",
-	Short: "Import organization teams into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting team data")
+		resources = append(resources, s.teamResource(team))
+	}
 
-		teams, err := getOrgTeams()
+	for _, team := range teams {
+		groups, err := s.getTeamSyncGroups(team)
+		if err == errTeamSyncUnavailable {
+			break
+		}
 		if err != nil {
-			return
+			return nil, err
 		}
 
-		output, err := os.Create(fmt.Sprintf("%s/github_team.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
+		if len(groups) == 0 {
+			continue
 		}
-		defer output.Close()
 
-		for _, team := range teams {
-			log.WithFields(logrus.Fields{
-				"Member": team.GetName(),
-			}).Debug("Processing team")
+		s.log.WithFields(logrus.Fields{
+			"Team": team.GetName(),
+		}).Debug("Processing team sync group mapping")
 
-			teamParse(team, output)
+		resources = append(resources, s.teamSyncGroupMappingResource(team, groups))
+	}
+
+	settings, err := s.getOrgTeamSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range teams {
+		// Teams without code review assignment are left with the provider defaults
+		teamSetting, ok := settings[team.GetSlug()]
+		if !ok || !teamSetting.ReviewRequestDelegationEnabled {
+			continue
 		}
-	},
+
+		s.log.WithFields(logrus.Fields{
+			"Team": team.GetName(),
+		}).Debug("Processing team settings")
+
+		resources = append(resources, s.teamSettingsResource(team, teamSetting))
+	}
+
+	return resources, nil
 }
 
-func getOrgTeams() ([]*github.Team, error) {
+func (s *session) getOrgTeams() ([]*github.Team, error) {
+	if s.archive != nil {
+		return s.archive.teams(), nil
+	}
+
+	if s.apiBackend == "graphql" {
+		return s.graphqlGetOrgTeams()
+	}
+
 	opt := &github.ListOptions{PerPage: 100}
 
 	var allTeams []*github.Team
 	for {
-		teams, resp, err := api.Teams.ListTeams(ctx, orgName, opt)
+		teams, resp, err := s.api.Teams.ListTeams(s.ctx, s.orgName, opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -77,25 +186,177 @@ func getOrgTeams() ([]*github.Team, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return allTeams, nil
 }
 
-func teamParse(team *github.Team, output *os.File) {
-	tmpl := template.Must(template.New("team").Funcs(templateFuncMap).Parse(teamTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org      string
-			Team     github.Team
-			ParentID int64
-		}{
-			Org:      orgName,
+// teamHierarchy indexes the organization teams to navigate between parent and child teams
+type teamHierarchy struct {
+	byID     map[int64]*github.Team
+	children map[int64][]*github.Team
+}
+
+func newTeamHierarchy(teams []*github.Team) *teamHierarchy {
+	hierarchy := &teamHierarchy{
+		byID:     make(map[int64]*github.Team),
+		children: make(map[int64][]*github.Team),
+	}
+
+	for _, team := range teams {
+		hierarchy.byID[team.GetID()] = team
+		if parentID := team.GetParent().GetID(); parentID != 0 {
+			hierarchy.children[parentID] = append(hierarchy.children[parentID], team)
+		}
+	}
+
+	return hierarchy
+}
+
+// parent returns the parent of the team, nil for top level teams
+func (h *teamHierarchy) parent(team *github.Team) *github.Team {
+	return h.byID[team.GetParent().GetID()]
+}
+
+// childTeams returns the teams directly nested under the team
+func (h *teamHierarchy) childTeams(team *github.Team) []*github.Team {
+	return h.children[team.GetID()]
+}
+
+func (s *session) getTeamSyncGroups(team *github.Team) ([]*github.IDPGroup, error) {
+	if s.archive != nil {
+		groups, err := s.archive.teamSyncGroups(team)
+		if err != nil {
+			s.log.Warn(err)
+		}
+		return groups, err
+	}
+
+	groups, resp, err := s.api.Teams.ListIDPGroupsForTeamBySlug(s.ctx, s.orgName, team.GetSlug())
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+			s.log.Warn(errTeamSyncUnavailable)
+			return nil, errTeamSyncUnavailable
+		}
+		s.log.Error(err)
+		return nil, err
+	}
+
+	return groups.Groups, nil
+}
+
+// getOrgTeamSettings returns the settings of every team in the organization indexed by team slug, nil when
+// they can't be read. The settings are optional, so that shouldn't stop the whole import.
+func (s *session) getOrgTeamSettings() (map[string]*TeamSettings, error) {
+	if s.archive != nil {
+		return s.archive.teamSettings(), nil
+	}
+
+	var cursor *string
+
+	allSettings := make(map[string]*TeamSettings)
+	for {
+		var result struct {
+			Organization struct {
+				Teams struct {
+					Nodes    []*TeamSettings `json:"nodes"`
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+				} `json:"teams"`
+			} `json:"organization"`
+		}
+
+		err := s.queryGraphQL(teamSettingsQuery, map[string]interface{}{"org": s.orgName, "cursor": cursor}, &result)
+		if err != nil {
+			s.log.WithFields(logrus.Fields{
+				"Error": err,
+			}).Warn("Team settings are not available, skipping them")
+			return nil, nil
+		}
+
+		for _, settings := range result.Organization.Teams.Nodes {
+			allSettings[settings.Slug] = settings
+		}
+
+		pageInfo := result.Organization.Teams.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		cursor = &pageInfo.EndCursor
+		s.log.Debugf("Fetching next page %s", *cursor)
+	}
+
+	return allSettings, nil
+}
+
+// TeamData is passed to the github_team template
+type TeamData struct {
+	// Org is the organization name
+	Org string
+	// Team is the Github team
+	Team github.Team
+	// ParentID is the parent team ID, 0 for top level teams
+	ParentID int64
+}
+
+func (s *session) teamResource(team *github.Team) *Resource {
+	return &Resource{
+		Type:     "github_team",
+		Name:     s.normalizeResourceName(team.GetName()),
+		ImportID: fmt.Sprintf("%d", team.GetID()),
+		Data: TeamData{
+			Org:      s.orgName,
 			Team:     *team,
 			ParentID: team.GetParent().GetID(),
-		})
-	if err != nil {
-		log.Error(err)
+		},
+	}
+}
+
+// TeamSyncGroupMappingData is passed to the github_team_sync_group_mapping template
+type TeamSyncGroupMappingData struct {
+	// Org is the organization name
+	Org string
+	// Team is the Github team
+	Team github.Team
+	// Groups are the identity provider groups synchronized with the team
+	Groups []*github.IDPGroup
+}
+
+func (s *session) teamSyncGroupMappingResource(team *github.Team, groups []*github.IDPGroup) *Resource {
+	return &Resource{
+		Type:     "github_team_sync_group_mapping",
+		Name:     s.normalizeResourceName(team.GetName()),
+		ImportID: team.GetSlug(),
+		Data: TeamSyncGroupMappingData{
+			Org:    s.orgName,
+			Team:   *team,
+			Groups: groups,
+		},
+	}
+}
+
+// TeamSettingsData is passed to the github_team_settings template
+type TeamSettingsData struct {
+	// Org is the organization name
+	Org string
+	// Team is the Github team
+	Team github.Team
+	// Settings are the code review assignment settings of the team
+	Settings TeamSettings
+}
+
+func (s *session) teamSettingsResource(team *github.Team, settings *TeamSettings) *Resource {
+	return &Resource{
+		Type:     "github_team_settings",
+		Name:     s.normalizeResourceName(team.GetName()),
+		ImportID: fmt.Sprintf("%d", team.GetID()),
+		Data: TeamSettingsData{
+			Org:      s.orgName,
+			Team:     *team,
+			Settings: *settings,
+		},
 	}
 }
@@ -2,75 +2,247 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"text/template"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const teamMembershipTemplate = `
 {{- if hasLeadingDigit .TeamName}}
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
-# Suggestion: use this identifier instead _{{normalizeResourceName .TeamName}}-{{.UserName}}
+# Suggestion: use this identifier instead _{{normalizeResourceName .TeamName}}-{{normalizeResourceName .UserName}}
 {{- end}}
-# terraform import github_team_membership.{{normalizeResourceName .TeamName}}-{{.UserName}} {{.TeamID}}:{{.UserName}}
-resource "github_team_membership" "{{normalizeResourceName .TeamName}}-{{.UserName}}" {
-  team_id  = {{.TeamID}}
-  username = "{{.UserName}}"
-  {{if .Role}}role     = "{{.Role}}"
-  {{end -}}
+# terraform import github_team_membership.{{normalizeResourceName .TeamName}}-{{normalizeResourceName .UserName}} {{.TeamID}}:{{.UserName}}
+resource "github_team_membership" "{{normalizeResourceName .TeamName}}-{{normalizeResourceName .UserName}}" {
+  team_id  = {{hclValue .TeamID}}
+  username = {{hclValue .UserName}}
+  {{- attr "role" .Role "member"}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(teamMembershipCmd)
+const teamMembersTemplate = `
+{{- if hasLeadingDigit .TeamName}}
+# WARNING this resource has an invalid identifier when used with Terraform 0.12+
+# Suggestion: use this identifier instead _{{normalizeResourceName .TeamName}}
+{{- end}}
+# terraform import github_team_members.{{normalizeResourceName .TeamName}} {{.TeamID}}
+resource "github_team_members" "{{normalizeResourceName .TeamName}}" {
+  team_id = {{hclValue .TeamID}}
+  {{- range .Members}}
+
+  members {
+    username = {{hclValue .UserName}}
+    {{- attr "role" .Role "member"}}
+  }
+  {{- end}}
+}
+`
+
+// TeamMember is a team member along with its role in the team
+type TeamMember struct {
+	UserName string
+	Role     string
 }
 
-var teamMembershipCmd = &cobra.Command{
-	Use:   "team-membership",
-	Short: "Import organization teams memberships into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting team membership data")
+var teamMembershipCmd = RegisterGenerator(&generator{
+	name:  "team-membership",
+	fetch: (*session).teamMembershipFetch,
+	templates: map[string]string{
+		"github_team_membership": teamMembershipTemplate,
+		"github_team_members":    teamMembersTemplate,
+	},
+	organizationOnly: true,
+}, "Import organization teams memberships into Terraform")
 
-		// first get teams, then for each team, get its members
-		teams, err := getOrgTeams()
-		if err != nil {
-			return
-		}
+func (s *session) teamMembershipFetch() ([]*Resource, error) {
+	s.log.Debug("Getting team membership data")
 
-		output, err := os.Create(fmt.Sprintf("%s/github_team_membership.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
+	// first get teams, then for each team, get its members
+	teams, err := s.getOrgTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.getOrgTeamsMemberships(teams)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := newTeamHierarchy(teams)
+
+	if s.authoritative {
+		return s.teamMembersFetch(teams, memberships, hierarchy)
+	}
+
+	var resources []*Resource
+	for _, role := range []string{"maintainer", "member"} {
+
+		for _, team := range teams {
+
+			for _, teamMember := range memberships[team.GetID()][role] {
+				s.log.WithFields(logrus.Fields{
+					"Team":   team.GetName(),
+					"Member": teamMember.GetLogin(),
+				}).Debug("Processing team membership")
+
+				child, err := memberships.inheritedFrom(s, team, teamMember, hierarchy)
+				if err != nil {
+					return nil, err
+				}
+				if child != nil {
+					resources = append(resources, s.teamMembershipChildComment("github_team_membership", team, teamMember, child))
+					continue
+				}
+
+				resources = append(resources, s.teamMembershipResource(team, teamMember, role))
+			}
 		}
-		defer output.Close()
+	}
 
-		for _, role := range []string{"maintainer", "member"} {
+	return resources, nil
+}
+
+// teamMembersFetch generates a single authoritative github_team_members resource per team
+func (s *session) teamMembersFetch(teams []*github.Team, memberships teamMemberships, hierarchy *teamHierarchy) ([]*Resource, error) {
+	var resources []*Resource
+	for _, team := range teams {
 
-			for _, team := range teams {
+		var members []TeamMember
+		for _, role := range []string{"maintainer", "member"} {
 
-				teamMembers, err := getOrgTeamMemberships(team, role)
+			for _, user := range memberships[team.GetID()][role] {
+				child, err := memberships.inheritedFrom(s, team, user, hierarchy)
 				if err != nil {
-					return
+					return nil, err
 				}
+				if child != nil {
+					resources = append(resources, s.teamMembershipChildComment("github_team_members", team, user, child))
+					continue
+				}
+
+				members = append(members, TeamMember{UserName: user.GetLogin(), Role: role})
+			}
+		}
+
+		// the provider doesn't allow a github_team_members without members
+		if len(members) == 0 {
+			continue
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"Team": team.GetName(),
+		}).Debug("Processing team members")
+
+		resources = append(resources, s.teamMembersResource(team, members))
+	}
+
+	return resources, nil
+}
+
+// teamMemberships holds the members of every team, indexed by team ID and role
+type teamMemberships map[int64]map[string][]*github.User
+
+func (s *session) getOrgTeamsMemberships(teams []*github.Team) (teamMemberships, error) {
+	memberships := make(teamMemberships)
+	for _, team := range teams {
+		memberships[team.GetID()] = make(map[string][]*github.User)
+
+		for _, role := range []string{"maintainer", "member"} {
+			teamMembers, err := s.getOrgTeamMemberships(team, role)
+			if err != nil {
+				return nil, err
+			}
+
+			memberships[team.GetID()][role] = teamMembers
+		}
+	}
+
+	return memberships, nil
+}
+
+// inheritedFrom returns the child team a member of the team comes from, or nil when the membership is direct.
+// Github lists the members of child teams as members of their parents, the immediate members of the team
+// tell the direct memberships apart.
+func (m teamMemberships) inheritedFrom(s *session, team *github.Team, user *github.User, hierarchy *teamHierarchy) (*github.Team, error) {
+	child := m.childTeam(team, user, hierarchy)
+	if child == nil {
+		return nil, nil
+	}
+
+	immediate, err := s.getOrgTeamImmediateMembers(team)
+	if err != nil {
+		return nil, err
+	}
+	if immediate[user.GetLogin()] {
+		return nil, nil
+	}
 
-				for _, teamMember := range teamMembers {
-					log.WithFields(logrus.Fields{
-						"Team":   teamMember.GetName(),
-						"Member": teamMember.GetName(),
-					}).Debug("Processing team membership")
+	return child, nil
+}
 
-					teamMembershipParse(team, teamMember, role, output)
+// childTeam returns the first child team of the team the user is a member of
+func (m teamMemberships) childTeam(team *github.Team, user *github.User, hierarchy *teamHierarchy) *github.Team {
+	for _, child := range hierarchy.childTeams(team) {
+		for _, members := range m[child.GetID()] {
+			for _, member := range members {
+				if member.GetLogin() == user.GetLogin() {
+					return child
 				}
 			}
 		}
+	}
 
-	},
+	return nil
+}
+
+func (s *session) teamMembershipChildComment(resourceType string, team *github.Team, user *github.User, child *github.Team) *Resource {
+	s.log.WithFields(logrus.Fields{
+		"Team":   team.GetName(),
+		"Member": user.GetLogin(),
+		"Child":  child.GetName(),
+	}).Debug("Skipping inherited team membership")
+
+	return commentResource(resourceType, "%s is a member of %s through the child team %s, skipping it", user.GetLogin(), team.GetName(), child.GetName())
 }
 
-func getOrgTeamMemberships(team *github.Team, role string) ([]*github.User, error) {
+// getOrgTeamImmediateMembers returns the members of the team itself, leaving out the members of its child teams
+func (s *session) getOrgTeamImmediateMembers(team *github.Team) (map[string]bool, error) {
+	if s.archive != nil {
+		members, err := s.archive.teamImmediateMembers(team)
+		if err != nil {
+			s.log.Error(err)
+		}
+		return members, err
+	}
+
+	if members, ok := s.teamImmediateMembers[team.GetID()]; ok {
+		return members, nil
+	}
+
+	members, err := s.graphqlGetOrgTeamImmediateMembers(team)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.teamImmediateMembers == nil {
+		s.teamImmediateMembers = make(map[int64]map[string]bool)
+	}
+	s.teamImmediateMembers[team.GetID()] = members
+
+	return members, nil
+}
+
+func (s *session) getOrgTeamMemberships(team *github.Team, role string) ([]*github.User, error) {
+	if s.archive != nil {
+		return s.archive.teamMembers(team, role), nil
+	}
+
+	if s.apiBackend == "graphql" {
+		if result, ok, err := s.graphqlGetOrgTeamMemberships(team, role); err != nil || ok {
+			return result, err
+		}
+	}
+
 	opt := &github.TeamListTeamMembersOptions{
 		Role:        role,
 		ListOptions: github.ListOptions{PerPage: 100},
@@ -78,9 +250,9 @@ func getOrgTeamMemberships(team *github.Team, role string) ([]*github.User, erro
 
 	var teamMembers []*github.User
 	for {
-		users, resp, err := api.Teams.ListTeamMembersBySlug(ctx, orgName, team.GetSlug(), opt)
+		users, resp, err := s.api.Teams.ListTeamMembersBySlug(s.ctx, s.orgName, team.GetSlug(), opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -89,29 +261,63 @@ func getOrgTeamMemberships(team *github.Team, role string) ([]*github.User, erro
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return teamMembers, nil
 }
 
-func teamMembershipParse(team *github.Team, user *github.User, role string, output *os.File) {
-	tmpl := template.Must(template.New("team-membership").Funcs(templateFuncMap).Parse(teamMembershipTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org      string
-			TeamID   int64
-			TeamName string
-			UserName string
-			Role     string
-		}{
-			Org:      orgName,
+// TeamMembershipData is passed to the github_team_membership template
+type TeamMembershipData struct {
+	// Org is the organization name
+	Org string
+	// TeamID is the team ID
+	TeamID int64
+	// TeamName is the team name
+	TeamName string
+	// UserName is the login of the user
+	UserName string
+	// Role is the role of the user
+	Role string
+}
+
+func (s *session) teamMembershipResource(team *github.Team, user *github.User, role string) *Resource {
+	return &Resource{
+		Type:     "github_team_membership",
+		Name:     fmt.Sprintf("%s-%s", s.normalizeResourceName(team.GetName()), s.normalizeResourceName(user.GetLogin())),
+		ImportID: fmt.Sprintf("%d:%s", team.GetID(), user.GetLogin()),
+		Data: TeamMembershipData{
+			Org:      s.orgName,
 			TeamID:   team.GetID(),
 			TeamName: team.GetName(),
 			UserName: user.GetLogin(),
 			Role:     role,
-		})
-	if err != nil {
-		log.Error(err)
+		},
+	}
+}
+
+// TeamMembersData is passed to the github_team_members template
+type TeamMembersData struct {
+	// Org is the organization name
+	Org string
+	// TeamID is the team ID
+	TeamID int64
+	// TeamName is the team name
+	TeamName string
+	// Members are the members of the team
+	Members []TeamMember
+}
+
+func (s *session) teamMembersResource(team *github.Team, members []TeamMember) *Resource {
+	return &Resource{
+		Type:     "github_team_members",
+		Name:     s.normalizeResourceName(team.GetName()),
+		ImportID: fmt.Sprintf("%d", team.GetID()),
+		Data: TeamMembersData{
+			Org:      s.orgName,
+			TeamID:   team.GetID(),
+			TeamName: team.GetName(),
+			Members:  members,
+		},
 	}
 }
@@ -2,87 +2,144 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"text/template"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 )
 
 const teamRepositoryTemplate = `
 {{- if hasLeadingDigit .TeamName}}
 # WARNING this resource has an invalid identifier when used with Terraform 0.12+
-# Suggestion: use this identifier instead _{{normalizeResourceName .TeamName}}-{{.RepoName}}
+# Suggestion: use this identifier instead _{{normalizeResourceName .TeamName}}-{{normalizeResourceName .RepoName}}
 {{- end}}
-# terraform import github_team_repository.{{normalizeResourceName .TeamName}}-{{.RepoName}} {{.TeamID}}:{{.RepoName}}
-resource "github_team_repository" "{{normalizeResourceName .TeamName}}-{{.RepoName}}" {
-  team_id    = {{.TeamID}}
-  repository = "{{.RepoName}}"
-  permission = "{{.Permission}}" 
+# terraform import github_team_repository.{{normalizeResourceName .TeamName}}-{{normalizeResourceName .RepoName}} {{.TeamID}}:{{.RepoName}}
+resource "github_team_repository" "{{normalizeResourceName .TeamName}}-{{normalizeResourceName .RepoName}}" {
+  team_id    = {{hclValue .TeamID}}
+  repository = {{hclValue .RepoName}}
+  {{- attr "permission" .Permission "pull"}}
 }
 `
 
-func init() {
-	rootCmd.AddCommand(teamRepositoryCmd)
-}
+var teamRepositoryCmd = RegisterGenerator(&generator{
+	name:  "team-repository",
+	fetch: (*session).teamRepositoryFetch,
+	templates: map[string]string{
+		"github_team_repository": teamRepositoryTemplate,
+	},
+	organizationOnly: true,
+}, "Import organization teams repositories into Terraform")
 
-var teamRepositoryCmd = &cobra.Command{
-	Use:   "team-repository",
-	Short: "Import organization teams repositories into Terraform",
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Debug("Getting team repository data")
+func (s *session) teamRepositoryFetch() ([]*Resource, error) {
+	s.log.Debug("Getting team repository data")
 
-		// first get teams, then for each team, get its repositories
-		teams, err := getOrgTeams()
-		if err != nil {
-			return
-		}
+	// first get teams, then for each team, get its repositories
+	teams, err := s.getOrgTeams()
+	if err != nil {
+		return nil, err
+	}
 
-		output, err := os.Create(fmt.Sprintf("%s/github_team_repository.tf", outDirectory))
-		if err != nil {
-			log.Error(err)
-			return
-		}
-		defer output.Close()
+	grants, err := s.getOrgTeamsRepositories(teams)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := newTeamHierarchy(teams)
 
-		for _, team := range teams {
+	var resources []*Resource
+	for _, team := range teams {
 
-			teamRepositories, err := getOrgTeamRepositorys(team)
-			if err != nil {
-				return
+		for _, repo := range grants[team.GetID()] {
+			s.log.WithFields(logrus.Fields{
+				"Team":       team.GetName(),
+				"Repository": repo.GetName(),
+			}).Debug("Processing team repository")
+
+			// Figure out the team permission for this repository
+			permission := highestPermission(repo.GetPermissions())
+			if permission == "" {
+				continue
 			}
 
-			for _, repo := range teamRepositories {
-				log.WithFields(logrus.Fields{
+			if parent := grants.inheritedFrom(team, repo, permission, hierarchy); parent != nil {
+				s.log.WithFields(logrus.Fields{
 					"Team":       team.GetName(),
 					"Repository": repo.GetName(),
-				}).Debug("Processing team membership")
-
-				// Figure out the team permission for this repository
-				// Order is admin, maintain, push(write), triage, pull(read)
-				permissions := repo.GetPermissions()
-				for _, permission := range []string{"admin", "maintain", "push", "triage", "pull"} {
-					if permissions[permission] {
-						teamRepositoryParse(team, repo, permission, output)
-						break
-					}
-				}
+					"Parent":     parent.GetName(),
+				}).Debug("Skipping inherited team repository")
 
+				resources = append(resources, commentResource("github_team_repository", "%s gets %s access to %s from the parent team %s, skipping it", team.GetName(), permission, repo.GetName(), parent.GetName()))
+				continue
 			}
+
+			resources = append(resources, s.teamRepositoryResource(team, repo, permission))
 		}
+	}
 
-	},
+	return resources, nil
 }
 
-func getOrgTeamRepositorys(team *github.Team) ([]*github.Repository, error) {
+// teamRepositories holds the repositories every team has access to, indexed by team ID
+type teamRepositories map[int64][]*github.Repository
+
+func (s *session) getOrgTeamsRepositories(teams []*github.Team) (teamRepositories, error) {
+	grants := make(teamRepositories)
+	for _, team := range teams {
+		repos, err := s.getOrgTeamRepositorys(team)
+		if err != nil {
+			return nil, err
+		}
+
+		grants[team.GetID()] = repos
+	}
+
+	return grants, nil
+}
+
+// inheritedFrom returns the ancestor team a repository grant comes from, or nil when the grant is direct.
+// Github lists the repositories of parent teams as repositories of their children, with either API, so
+// the grants of the parent team are subtracted: granting the child team the permission its parent already
+// has doesn't give it any access, only higher permissions have to be granted to the child team itself.
+func (g teamRepositories) inheritedFrom(team *github.Team, repo *github.Repository, permission string, hierarchy *teamHierarchy) *github.Team {
+	var origin *github.Team
+	for parent := hierarchy.parent(team); parent != nil; parent = hierarchy.parent(parent) {
+		if g.permission(parent, repo) != permission {
+			break
+		}
+		origin = parent
+	}
+
+	return origin
+}
+
+// permission returns the team permission on the repository, empty when the team has no access to it
+func (g teamRepositories) permission(team *github.Team, repo *github.Repository) string {
+	for _, teamRepo := range g[team.GetID()] {
+		if teamRepo.GetID() == repo.GetID() {
+			return highestPermission(teamRepo.GetPermissions())
+		}
+	}
+
+	return ""
+}
+
+func (s *session) getOrgTeamRepositorys(team *github.Team) ([]*github.Repository, error) {
+	if s.archive != nil {
+		return s.archive.team(team).Repositories, nil
+	}
+
+	if s.apiBackend == "graphql" {
+		if result, ok, err := s.graphqlGetOrgTeamRepositories(team); err != nil || ok {
+			return result, err
+		}
+	}
+
 	opt := &github.ListOptions{PerPage: 100}
 
 	var teamRepositories []*github.Repository
 	for {
-		repos, resp, err := api.Teams.ListTeamReposBySlug(ctx, orgName, team.GetSlug(), opt)
+		repos, resp, err := s.api.Teams.ListTeamReposBySlug(s.ctx, s.orgName, team.GetSlug(), opt)
 		if err != nil {
-			log.Error(err)
+			s.log.Error(err)
 			return nil, err
 		}
 
@@ -91,29 +148,37 @@ func getOrgTeamRepositorys(team *github.Team) ([]*github.Repository, error) {
 			break
 		}
 		opt.Page = resp.NextPage
-		log.Debugf("Fetching next page %d", opt.Page)
+		s.log.Debugf("Fetching next page %d", opt.Page)
 	}
 
 	return teamRepositories, nil
 }
 
-func teamRepositoryParse(team *github.Team, repo *github.Repository, permission string, output *os.File) {
-	tmpl := template.Must(template.New("team-repository").Funcs(templateFuncMap).Parse(teamRepositoryTemplate))
-	err := tmpl.Execute(output,
-		struct {
-			Org        string
-			TeamID     int64
-			TeamName   string
-			RepoName   string
-			Permission string
-		}{
-			Org:        orgName,
+// TeamRepositoryData is passed to the github_team_repository template
+type TeamRepositoryData struct {
+	// Org is the organization name
+	Org string
+	// TeamID is the team ID
+	TeamID int64
+	// TeamName is the team name
+	TeamName string
+	// RepoName is the repository name
+	RepoName string
+	// Permission is the permission granted on the repository
+	Permission string
+}
+
+func (s *session) teamRepositoryResource(team *github.Team, repo *github.Repository, permission string) *Resource {
+	return &Resource{
+		Type:     "github_team_repository",
+		Name:     fmt.Sprintf("%s-%s", s.normalizeResourceName(team.GetName()), s.normalizeResourceName(repo.GetName())),
+		ImportID: fmt.Sprintf("%d:%s", team.GetID(), repo.GetName()),
+		Data: TeamRepositoryData{
+			Org:        s.orgName,
 			TeamID:     team.GetID(),
 			TeamName:   team.GetName(),
 			RepoName:   repo.GetName(),
 			Permission: permission,
-		})
-	if err != nil {
-		log.Error(err)
+		},
 	}
 }
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// templateExtension is the extension of the template files read from the template directory
+const templateExtension = ".tmpl"
+
+// templateSource is implemented by the generators rendering their resources with templates
+type templateSource interface {
+	// Templates returns the built-in templates indexed by name
+	Templates() map[string]string
+}
+
+var templatesDumpForce bool
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+	templatesCmd.AddCommand(templatesDumpCmd)
+
+	templatesDumpCmd.Flags().BoolVar(&templatesDumpForce, "force", false, "Overwrite the template files that already exist")
+}
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage the templates used to render the resources",
+}
+
+var templatesDumpCmd = &cobra.Command{
+	Use:   "dump [DIR]",
+	Short: "Write the built-in templates to a directory, to be customized and used with --template-dir",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := templateDirectory
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if dir == "" {
+			dir = filepath.Join(outDirectory, "templates")
+		}
+
+		if err := dumpTemplates(dir, templatesDumpForce); err != nil {
+			log.Error(err)
+		}
+	},
+}
+
+// TemplateFuncs returns the functions available to the resource templates, normalizeResourceName
+// uses the default naming
+func TemplateFuncs() template.FuncMap {
+	return (&session{}).templateFuncs()
+}
+
+// loadTemplate returns the template with the given name from the template directory, or the
+// built-in one when the directory isn't set or doesn't override it
+func (s *session) loadTemplate(name, builtin string) (string, error) {
+	if s.templateDirectory == "" {
+		return builtin, nil
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join(s.templateDirectory, name+templateExtension))
+	if os.IsNotExist(err) {
+		return builtin, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
+
+// dumpTemplates writes the built-in templates of every generator to the directory
+func dumpTemplates(dir string, force bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	templates := make(map[string]string)
+	for _, g := range Generators() {
+		source, ok := g.(templateSource)
+		if !ok {
+			continue
+		}
+		for name, text := range source.Templates() {
+			templates[name] = text
+		}
+	}
+
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var written int
+	for _, name := range names {
+		path := filepath.Join(dir, name+templateExtension)
+
+		if _, err := os.Stat(path); err == nil && !force {
+			log.WithFields(logrus.Fields{
+				"File": path,
+			}).Warn("Template already exists, use --force to overwrite it")
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"File": path,
+		}).Debug("Writing template")
+
+		if err := ioutil.WriteFile(path, []byte(templates[name]), 0644); err != nil {
+			return fmt.Errorf("writing template %s: %v", name, err)
+		}
+		written++
+	}
+
+	log.WithFields(logrus.Fields{
+		"Directory": dir,
+		"Templates": written,
+	}).Info("Templates written")
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// terraformJSON is a configuration file in the Terraform JSON syntax
+type terraformJSON struct {
+	Comment  string                                       `json:"//,omitempty"`
+	Provider map[string]interface{}                       `json:"provider,omitempty"`
+	Resource map[string]map[string]map[string]interface{} `json:"resource,omitempty"`
+	Import   []terraformJSONImport                        `json:"import,omitempty"`
+}
+
+// terraformJSONImport is an import block, importing an existing object into a resource
+type terraformJSONImport struct {
+	To string `json:"to"`
+	ID string `json:"id"`
+}
+
+// addResource adds the resource rendered by the generator to the configuration, along with its import block
+func (t *terraformJSON) addResource(g Generator, resource *Resource, code []byte) error {
+	if resource.Data == nil {
+		t.addComment(resource.Comment)
+		return nil
+	}
+
+	file, diags := hclsyntax.ParseConfig(code, resource.Type, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 {
+			continue
+		}
+		resourceType, name := block.Labels[0], block.Labels[1]
+
+		body, err := hclBodyJSON(block.Body, code, false)
+		if err != nil {
+			return err
+		}
+
+		if t.Resource == nil {
+			t.Resource = make(map[string]map[string]map[string]interface{})
+		}
+		if t.Resource[resourceType] == nil {
+			t.Resource[resourceType] = make(map[string]map[string]interface{})
+		}
+		t.Resource[resourceType][name] = body
+
+		if id := g.ImportID(resource); id != "" {
+			t.Import = append(t.Import, terraformJSONImport{
+				To: fmt.Sprintf("%s.%s", resourceType, name),
+				ID: id,
+			})
+		}
+	}
+
+	return nil
+}
+
+func (t *terraformJSON) addComment(comment string) {
+	if t.Comment != "" {
+		t.Comment += "\n"
+	}
+	t.Comment += comment
+}
+
+// merge adds the content of another configuration
+func (t *terraformJSON) merge(other *terraformJSON) {
+	if other.Comment != "" {
+		t.addComment(other.Comment)
+	}
+
+	for name, provider := range other.Provider {
+		if t.Provider == nil {
+			t.Provider = make(map[string]interface{})
+		}
+		t.Provider[name] = provider
+	}
+
+	for resourceType, resources := range other.Resource {
+		if t.Resource == nil {
+			t.Resource = make(map[string]map[string]map[string]interface{})
+		}
+		if t.Resource[resourceType] == nil {
+			t.Resource[resourceType] = make(map[string]map[string]interface{})
+		}
+		for name, resource := range resources {
+			t.Resource[resourceType][name] = resource
+		}
+	}
+
+	t.Import = append(t.Import, other.Import...)
+}
+
+func (t *terraformJSON) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// renderJSONResources renders the resources in the Terraform JSON syntax indexed by output file
+func (s *session) renderJSONResources(g Generator, resources []*Resource) (map[string][]byte, error) {
+	documents := make(map[string]*terraformJSON)
+	for _, resource := range resources {
+		file := s.config.outputFile(g, resource) + ".json"
+
+		document, ok := documents[file]
+		if !ok {
+			document = new(terraformJSON)
+			documents[file] = document
+		}
+
+		var code []byte
+		if resource.Data != nil {
+			var err error
+			if code, err = s.renderResource(g, resource); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := document.addResource(g, resource, code); err != nil {
+			return nil, err
+		}
+	}
+
+	files := make(map[string][]byte)
+	for file, document := range documents {
+		data, err := document.marshal()
+		if err != nil {
+			return nil, err
+		}
+		files[file] = data
+	}
+
+	return files, nil
+}
+
+// mergeJSONFile merges the configuration with the one already written to the file
+func mergeJSONFile(path string, content []byte) ([]byte, error) {
+	existing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	document, other := new(terraformJSON), new(terraformJSON)
+	for _, d := range []struct {
+		data     []byte
+		document *terraformJSON
+	}{{existing, document}, {content, other}} {
+		// keep the numbers as they were written
+		decoder := json.NewDecoder(bytes.NewReader(d.data))
+		decoder.UseNumber()
+		if err := decoder.Decode(d.document); err != nil {
+			return nil, err
+		}
+	}
+	document.merge(other)
+
+	return document.marshal()
+}
+
+// hclBodyJSON converts a block body into its JSON representation, nested blocks become objects, or lists
+// of objects when a block type is repeated
+func hclBodyJSON(body *hclsyntax.Body, src []byte, lifecycle bool) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	for name, attribute := range body.Attributes {
+		switch {
+		// meta-arguments referencing objects are given as plain strings in the JSON syntax
+		case name == "provider", lifecycle && name == "ignore_changes":
+			result[name] = hclReferencesJSON(attribute.Expr, src)
+		default:
+			value, err := hclExpressionJSON(attribute.Expr, src)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = value
+		}
+	}
+
+	blocks := make(map[string][]interface{})
+	var blockTypes []string
+	for _, block := range body.Blocks {
+		nested, err := hclBodyJSON(block.Body, src, block.Type == "lifecycle")
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := blocks[block.Type]; !ok {
+			blockTypes = append(blockTypes, block.Type)
+		}
+		blocks[block.Type] = append(blocks[block.Type], nested)
+	}
+
+	for _, blockType := range blockTypes {
+		if len(blocks[blockType]) == 1 {
+			result[blockType] = blocks[blockType][0]
+		} else {
+			result[blockType] = blocks[blockType]
+		}
+	}
+
+	return result, nil
+}
+
+// hclExpressionJSON converts an expression into a JSON value, expressions that can't be evaluated on their
+// own, like references to other resources, become interpolations
+func hclExpressionJSON(expr hclsyntax.Expression, src []byte) (interface{}, error) {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return fmt.Sprintf("${%s}", hclSource(expr, src)), nil
+	}
+
+	return ctyJSON(value)
+}
+
+// hclReferencesJSON converts a reference, or a list of references, into strings
+func hclReferencesJSON(expr hclsyntax.Expression, src []byte) interface{} {
+	if tuple, ok := expr.(*hclsyntax.TupleConsExpr); ok {
+		references := make([]string, 0, len(tuple.Exprs))
+		for _, elem := range tuple.Exprs {
+			references = append(references, hclSource(elem, src))
+		}
+		return references
+	}
+
+	return hclSource(expr, src)
+}
+
+func hclSource(expr hclsyntax.Expression, src []byte) string {
+	r := expr.Range()
+	return strings.TrimSpace(string(src[r.Start.Byte:r.End.Byte]))
+}
+
+// ctyJSON converts a value into a JSON value, escaping the strings so they aren't taken for templates
+func ctyJSON(value cty.Value) (interface{}, error) {
+	if value.IsNull() {
+		return nil, nil
+	}
+
+	t := value.Type()
+	switch {
+	case t == cty.String:
+		return strings.NewReplacer("${", "$${", "%{", "%%{").Replace(value.AsString()), nil
+	case t == cty.Bool:
+		return value.True(), nil
+	case t == cty.Number:
+		return json.Number(value.AsBigFloat().Text('f', -1)), nil
+	case t.IsListType() || t.IsTupleType() || t.IsSetType():
+		elems := make([]interface{}, 0, value.LengthInt())
+		for it := value.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			converted, err := ctyJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, converted)
+		}
+		return elems, nil
+	case t.IsMapType() || t.IsObjectType():
+		attrs := make(map[string]interface{})
+		for it := value.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			converted, err := ctyJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			attrs[key.AsString()] = converted
+		}
+		return attrs, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %s", t.FriendlyName())
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCtyJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   cty.Value
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "null", value: cty.NullVal(cty.String), want: nil},
+		{name: "string", value: cty.StringVal("main"), want: "main"},
+		{name: "escaped string", value: cty.StringVal("${var} and %{if}"), want: "$${var} and %%{if}"},
+		{name: "bool", value: cty.False, want: false},
+		{name: "int", value: cty.NumberIntVal(42), want: json.Number("42")},
+		{name: "float", value: cty.NumberFloatVal(1.5), want: json.Number("1.5")},
+		{name: "empty tuple", value: cty.EmptyTupleVal, want: []interface{}{}},
+		{name: "list", value: cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}), want: []interface{}{"a", "b"}},
+		{name: "set", value: cty.SetVal([]cty.Value{cty.StringVal("a")}), want: []interface{}{"a"}},
+		{
+			name:  "object",
+			value: cty.ObjectVal(map[string]cty.Value{"enabled": cty.True, "events": cty.TupleVal([]cty.Value{cty.StringVal("push")})}),
+			want:  map[string]interface{}{"enabled": true, "events": []interface{}{"push"}},
+		},
+		{name: "map", value: cty.MapVal(map[string]cty.Value{"a": cty.NumberIntVal(1)}), want: map[string]interface{}{"a": json.Number("1")}},
+		{name: "unknown type", value: cty.CapsuleVal(cty.Capsule("test", reflect.TypeOf(0)), new(int)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ctyJSON(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ctyJSON(%#v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ctyJSON(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
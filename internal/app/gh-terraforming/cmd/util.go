@@ -3,13 +3,18 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/google/go-github/v32/github"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func replace(input, from, to string) string {
@@ -45,25 +50,190 @@ func quoteIfString(i interface{}) interface{} {
 	return i
 }
 
-func normalizeResourceName(name string) string {
+// highestPermission returns the highest permission granted on a repository
+// Order is admin, maintain, push(write), triage, pull(read)
+func highestPermission(permissions map[string]bool) string {
+	for _, permission := range []string{"admin", "maintain", "push", "triage", "pull"} {
+		if permissions[permission] {
+			return permission
+		}
+	}
+
+	return ""
+}
+
+// apiPermission converts the read and write permission names, used by some endpoints, to the ones used by Terraform
+func apiPermission(permission string) string {
+	switch permission {
+	case "read":
+		return "pull"
+	case "write":
+		return "push"
+	}
+
+	return permission
+}
+
+// permissionRank orders repository permissions from pull(read) to admin, no access ranks lowest
+func permissionRank(permission string) int {
+	for i, p := range []string{"pull", "triage", "push", "maintain", "admin"} {
+		if p == permission {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func (s *session) normalizeResourceName(name string) string {
+	if s.config != nil && s.config.Naming == "snake_case" {
+		return snakeCaseName(name)
+	}
+
 	r := strings.NewReplacer(".", "_", "*", "star", " ", "_")
 
-	return r.Replace(name)
+	// anything else Terraform doesn't allow in identifiers, e.g. the colon of a "type: bug" label
+	return invalidResourceNameChars.ReplaceAllString(r.Replace(name), "_")
 }
 
+var invalidResourceNameChars = regexp.MustCompile(`[^\p{L}\p{N}_-]`)
+
 func hasLeadingDigit(identifier string) bool {
 	_, err := strconv.ParseFloat(identifier[:1], 64)
 	return err == nil
 }
 
-var templateFuncMap = template.FuncMap{
-	"replace":               replace,
-	"isMap":                 isMap,
-	"isSlice":               isSlice,
-	"quoteIfString":         quoteIfString,
-	"trim":                  strings.TrimSpace,
-	"normalizeResourceName": normalizeResourceName,
-	"hasLeadingDigit":       hasLeadingDigit,
+// hclValue converts a template value into its HCL literal representation, strings are quoted and
+// escaped so they can hold any character. Values that have no HCL representation are an error.
+func hclValue(i interface{}) (string, error) {
+	value, err := ctyValue(reflect.ValueOf(i))
+	if err != nil {
+		return "", err
+	}
+
+	return string(hclwrite.TokensForValue(value).Bytes()), nil
+}
+
+func ctyValue(v reflect.Value) (cty.Value, error) {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return cty.StringVal(v.String()), nil
+	case reflect.Bool:
+		return cty.BoolVal(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cty.NumberIntVal(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cty.NumberUIntVal(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cty.NumberFloatVal(v.Float()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		var elems []cty.Value
+		for i := 0; i < v.Len(); i++ {
+			elem, err := ctyValue(v.Index(i))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, elem)
+		}
+		return cty.TupleVal(elems), nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return cty.NilVal, fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		if v.Len() == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		attrs := make(map[string]cty.Value)
+		for _, key := range v.MapKeys() {
+			elem, err := ctyValue(v.MapIndex(key))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key.String()] = elem
+		}
+		return cty.ObjectVal(attrs), nil
+	case reflect.Invalid:
+		return cty.NilVal, fmt.Errorf("no value")
+	default:
+		return cty.NilVal, fmt.Errorf("unsupported value of type %s", v.Type())
+	}
+}
+
+// attr renders an optional resource attribute, but only when its value differs from the default the
+// provider assumes when the attribute is omitted. A nil default means the attribute has no default
+// or it isn't reliable, so the attribute is always rendered. Unknown values (nil pointers) and empty
+// lists are never rendered.
+//
+// The attribute is rendered on a new line so it's meant to be used as {{- attr ...}}, indentation
+// and alignment are fixed afterwards by executeTemplate.
+func attr(name string, value, defaultValue interface{}) (string, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+		return "", nil
+	}
+
+	if defaultValue != nil && fmt.Sprintf("%v", v.Interface()) == fmt.Sprintf("%v", defaultValue) {
+		return "", nil
+	}
+
+	literal, err := hclValue(v.Interface())
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+
+	return fmt.Sprintf("\n%s = %s", name, literal), nil
+}
+
+// templateFuncs returns the functions available to the resource templates, resource names are
+// normalized with the naming of the session
+func (s *session) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"replace":               replace,
+		"isMap":                 isMap,
+		"isSlice":               isSlice,
+		"quoteIfString":         quoteIfString,
+		"trim":                  strings.TrimSpace,
+		"normalizeResourceName": s.normalizeResourceName,
+		"hasLeadingDigit":       hasLeadingDigit,
+		"hclValue":              hclValue,
+		"attr":                  attr,
+	}
+}
+
+// executeTemplate renders a resource template and formats the result the same way
+// terraform fmt does, so templates don't need to care about alignment
+func executeTemplate(tmpl *template.Template, output io.Writer, data interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	_, err := output.Write(hclwrite.Format(buf.Bytes()))
+	return err
+}
+
+// apiGet fetches a REST endpoint that isn't wrapped by go-github yet and decodes the response into v
+func (s *session) apiGet(path string, v interface{}) (*github.Response, error) {
+	req, err := s.api.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.api.Do(s.ctx, req, v)
 }
 
 func hashMap(values map[string]string) int {
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestHCLValue(t *testing.T) {
+	text := "a \"quoted\" ${interpolation} and %{directive}"
+	var nilString *string
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "string", value: "main", want: `"main"`},
+		{name: "escaped string", value: text, want: `"a \"quoted\" $${interpolation} and %%{directive}"`},
+		{name: "string pointer", value: &text, want: `"a \"quoted\" $${interpolation} and %%{directive}"`},
+		{name: "bool", value: true, want: "true"},
+		{name: "int", value: 42, want: "42"},
+		{name: "int64", value: int64(-7), want: "-7"},
+		{name: "uint", value: uint(7), want: "7"},
+		{name: "float", value: 1.5, want: "1.5"},
+		{name: "empty list", value: []string{}, want: "[]"},
+		{name: "nil list", value: []string(nil), want: "[]"},
+		{name: "list", value: []string{"push", "pull_request"}, want: `["push", "pull_request"]`},
+		{name: "int list", value: []int64{1, 2}, want: "[1, 2]"},
+		{name: "map", value: map[string]interface{}{"b": 1, "a": "x"}, want: "{\n  a = \"x\"\n  b = 1\n}"},
+		{name: "nil", value: nil, wantErr: true},
+		{name: "nil pointer", value: nilString, wantErr: true},
+		{name: "struct", value: struct{ Name string }{"x"}, wantErr: true},
+		{name: "map with int keys", value: map[int]string{1: "x"}, wantErr: true},
+		{name: "list of structs", value: []struct{}{{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hclValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hclValue(%#v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("hclValue(%#v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttr(t *testing.T) {
+	enabled, disabled := true, false
+	var unknown *bool
+
+	tests := []struct {
+		name         string
+		value        interface{}
+		defaultValue interface{}
+		want         string
+		wantErr      bool
+	}{
+		{name: "differs from default", value: "private", defaultValue: "public", want: "\nattribute = \"private\""},
+		{name: "equals default", value: "public", defaultValue: "public", want: ""},
+		{name: "no default", value: false, defaultValue: nil, want: "\nattribute = false"},
+		{name: "empty string without default", value: "", defaultValue: nil, want: "\nattribute = \"\""},
+		{name: "pointer differs from default", value: &enabled, defaultValue: false, want: "\nattribute = true"},
+		{name: "pointer equals default", value: &disabled, defaultValue: false, want: ""},
+		{name: "nil pointer", value: unknown, defaultValue: nil, want: ""},
+		{name: "nil", value: nil, defaultValue: nil, want: ""},
+		{name: "empty list", value: []string{}, defaultValue: nil, want: ""},
+		{name: "list", value: []string{"a"}, defaultValue: nil, want: "\nattribute = [\"a\"]"},
+		{name: "number equals default", value: 0, defaultValue: 0, want: ""},
+		{name: "escaped string", value: `say "hi"`, defaultValue: "", want: "\nattribute = \"say \\\"hi\\\"\""},
+		{name: "unsupported value", value: struct{}{}, defaultValue: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := attr("attribute", tt.value, tt.defaultValue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("attr(%#v, %#v) error = %v, wantErr %v", tt.value, tt.defaultValue, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("attr(%#v, %#v) = %q, want %q", tt.value, tt.defaultValue, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighestPermission(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions map[string]bool
+		want        string
+	}{
+		{name: "admin", permissions: map[string]bool{"admin": true, "maintain": true, "push": true, "triage": true, "pull": true}, want: "admin"},
+		{name: "maintain", permissions: map[string]bool{"admin": false, "maintain": true, "push": true, "pull": true}, want: "maintain"},
+		{name: "push", permissions: map[string]bool{"push": true, "triage": true, "pull": true}, want: "push"},
+		{name: "triage", permissions: map[string]bool{"triage": true, "pull": true}, want: "triage"},
+		{name: "pull", permissions: map[string]bool{"pull": true}, want: "pull"},
+		{name: "no access", permissions: map[string]bool{"admin": false, "pull": false}, want: ""},
+		{name: "nil", permissions: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highestPermission(tt.permissions); got != tt.want {
+				t.Errorf("highestPermission(%v) = %q, want %q", tt.permissions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissionRank(t *testing.T) {
+	ordered := []string{"", "pull", "triage", "push", "maintain", "admin"}
+	for i := 1; i < len(ordered); i++ {
+		if permissionRank(ordered[i-1]) >= permissionRank(ordered[i]) {
+			t.Errorf("permissionRank(%q) = %d, want lower than permissionRank(%q) = %d", ordered[i-1], permissionRank(ordered[i-1]), ordered[i], permissionRank(ordered[i]))
+		}
+	}
+
+	for _, permission := range []string{"", "read", "write", "owner"} {
+		if got := permissionRank(permission); got != 0 {
+			t.Errorf("permissionRank(%q) = %d, want 0", permission, got)
+		}
+	}
+}
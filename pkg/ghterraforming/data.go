@@ -0,0 +1,54 @@
+package ghterraforming
+
+import "github.com/lneves75/gh-terraforming/internal/app/gh-terraforming/cmd"
+
+// The Data of the resources, one type per Terraform resource type, e.g. a github_repository resource holds
+// a RepositoryData. Comment resources have no Data.
+type (
+	ActionsOrganizationPermissionsData = cmd.ActionsOrganizationPermissionsData
+	ActionsRepositoryAccessLevelData   = cmd.ActionsRepositoryAccessLevelData
+	ActionsRepositoryPermissionsData   = cmd.ActionsRepositoryPermissionsData
+	IssueLabelData                     = cmd.IssueLabelData
+	IssueLabelsData                    = cmd.IssueLabelsData
+	MembershipData                     = cmd.MembershipData
+	OrganizationBlockData              = cmd.OrganizationBlockData
+	OrganizationRulesetData            = cmd.OrganizationRulesetData
+	RepositoryBranchData               = cmd.RepositoryBranchData
+	RepositoryBranchDefaultData        = cmd.RepositoryBranchDefaultData
+	RepositoryCollaboratorData         = cmd.RepositoryCollaboratorData
+	RepositoryCollaboratorsData        = cmd.RepositoryCollaboratorsData
+	RepositoryData                     = cmd.RepositoryData
+	RepositoryRulesetData              = cmd.RepositoryRulesetData
+	RepositoryWebhookData              = cmd.RepositoryWebhookData
+	TeamData                           = cmd.TeamData
+	TeamMembersData                    = cmd.TeamMembersData
+	TeamMembershipData                 = cmd.TeamMembershipData
+	TeamRepositoryData                 = cmd.TeamRepositoryData
+	TeamSettingsData                   = cmd.TeamSettingsData
+	TeamSyncGroupMappingData           = cmd.TeamSyncGroupMappingData
+)
+
+// The types of the Data fields that go-github doesn't provide
+type (
+	ActionsPermissions             = cmd.ActionsPermissions
+	ActionsSelectedActions         = cmd.ActionsSelectedActions
+	RepositoryDetails              = cmd.RepositoryDetails
+	RepositoryGrant                = cmd.RepositoryGrant
+	RepositoryPages                = cmd.RepositoryPages
+	RepositorySecurityAndAnalysis  = cmd.RepositorySecurityAndAnalysis
+	RepositorySecurityStatus       = cmd.RepositorySecurityStatus
+	Ruleset                        = cmd.Ruleset
+	RulesetBypassActor             = cmd.RulesetBypassActor
+	RulesetBypassActorData         = cmd.RulesetBypassActorData
+	RulesetCodeScanningTool        = cmd.RulesetCodeScanningTool
+	RulesetConditions              = cmd.RulesetConditions
+	RulesetRefNameCondition        = cmd.RulesetRefNameCondition
+	RulesetRepositoryIDCondition   = cmd.RulesetRepositoryIDCondition
+	RulesetRepositoryNameCondition = cmd.RulesetRepositoryNameCondition
+	RulesetRule                    = cmd.RulesetRule
+	RulesetRuleParameters          = cmd.RulesetRuleParameters
+	RulesetStatusCheck             = cmd.RulesetStatusCheck
+	RulesetWorkflow                = cmd.RulesetWorkflow
+	TeamMember                     = cmd.TeamMember
+	TeamSettings                   = cmd.TeamSettings
+)
@@ -0,0 +1,52 @@
+// Package ghterraforming generates Terraform resources describing an existing Github organization.
+//
+// It runs the same generators as the gh-terraforming command line, but takes a configured
+// Github client and returns the resources instead of writing files:
+//
+//	results, err := ghterraforming.Generate(ctx, client, "acme", ghterraforming.Options{
+//		Generators: []string{"repository", "team"},
+//	})
+//	for _, result := range results {
+//		for file, hcl := range result.Files {
+//			...
+//		}
+//	}
+package ghterraforming
+
+import (
+	"context"
+	"text/template"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/lneves75/gh-terraforming/internal/app/gh-terraforming/cmd"
+)
+
+// Resource is a single Terraform resource, with its type, name, import ID and the data its template renders
+type Resource = cmd.Resource
+
+// Options configures which generators run and how, see cmd.GenerateOptions
+type Options = cmd.GenerateOptions
+
+// Result holds the resources produced by a generator, both as structured values and as HCL indexed by file name
+type Result = cmd.Generated
+
+// Generate runs the generators against the organization using the client, which should be authenticated
+// with a token that can read the organization. Calls don't share any state and can run concurrently.
+func Generate(ctx context.Context, client *github.Client, org string, opts Options) ([]*Result, error) {
+	return cmd.Generate(ctx, client, org, opts)
+}
+
+// Generators returns the names of the available generators, the same as the command line resource commands
+func Generators() []string {
+	var names []string
+	for _, g := range cmd.Generators() {
+		names = append(names, g.Name())
+	}
+
+	return names
+}
+
+// TemplateFuncs returns the functions available to the templates of Options.TemplateDir
+func TemplateFuncs() template.FuncMap {
+	return cmd.TemplateFuncs()
+}